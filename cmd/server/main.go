@@ -1,16 +1,30 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"codec-svr/internal/codec/fmxxx"
 	"codec-svr/internal/config"
+	"codec-svr/internal/dispatcher"
 	"codec-svr/internal/observability"
+	"codec-svr/internal/profile"
 	"codec-svr/internal/server"
+	"codec-svr/internal/session"
 	"codec-svr/internal/store"
 )
 
 func main() {
 	cfg := config.Load()
 	logger := observability.NewLogger()
-	logger.Info("Starting codec-svr...", "port", cfg.TCPPort)
+	logger.Info("Starting codec-svr...", "port", cfg.TCPPort, "udp_port", cfg.UDPPort)
+
+	// SIGTERM (systemctl stop/restart) o Ctrl-C inician el apagado ordenado
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
 	// Inicializar Redis antes del server
 	if err := store.InitRedis("localhost:6379", 0); err != nil {
@@ -18,10 +32,105 @@ func main() {
 		return
 	}
 
-	go observability.StartMetricsServer(cfg.MetricsPort)
+	dispatcher.LogRawFrames = cfg.LogRawFrames
+	dispatcher.StartWorkers(cfg.WorkerShards, cfg.WorkerQueue)
+	policy, err := session.ParsePolicy(cfg.DupSessionPolicy)
+	if err != nil {
+		logger.Error("invalid DUP_SESSION_POLICY", "error", err)
+		return
+	}
+	session.DupPolicy = policy
+	if cfg.IOCatalogPath != "" {
+		cat, err := fmxxx.LoadCatalog(cfg.IOCatalogPath)
+		if err != nil {
+			logger.Error("IO catalog load failed", "path", cfg.IOCatalogPath, "error", err)
+			return
+		}
+		dispatcher.IOCatalog = cat
+		logger.Info("IO catalog loaded", "path", cfg.IOCatalogPath, "elements", len(cat.Elements))
+	}
+	if cfg.ProfilesPath != "" {
+		if err := profile.Load(cfg.ProfilesPath); err != nil {
+			logger.Error("device profiles load failed", "path", cfg.ProfilesPath, "error", err)
+			return
+		}
+		logger.Info("device profiles loaded", "path", cfg.ProfilesPath)
+	}
+
+	go observability.StartMetricsServer(ctx, cfg.MetricsPort)
+
+	// Admin (cuarentena, sesiones, comandos) en su propio listener
+	if cfg.AdminToken == "" {
+		logger.Warn("ADMIN_TOKEN not set: admin endpoints rely on ADMIN_ADDR being private", "addr", cfg.AdminAddr)
+	}
+	go func() {
+		if err := server.StartAdmin(ctx, cfg.AdminAddr, cfg.AdminToken); err != nil {
+			logger.Error("admin server failed", "error", err)
+		}
+	}()
+
+	// Listeners que también encolan en el dispatcher: hay que esperarlos
+	// antes de Drain
+	var listeners sync.WaitGroup
 
-	// Inicia el servidor TCP directamente
-	if err := server.Start(":" + cfg.TCPPort); err != nil {
+	// Listener UDP en paralelo al TCP
+	listeners.Add(1)
+	go func() {
+		defer listeners.Done()
+		if err := server.StartUDP(ctx, ":"+cfg.UDPPort); err != nil {
+			logger.Error("UDP server failed", "error", err)
+		}
+	}()
+
+	// Archivos de cámaras (DualCam / ADAS), sólo si hay CAMERA_PORT
+	if cfg.CameraPort != "" {
+		listeners.Add(1)
+		go func() {
+			defer listeners.Done()
+			if err := server.StartCamera(ctx, ":"+cfg.CameraPort, cfg.MediaDir); err != nil {
+				logger.Error("camera server failed", "error", err)
+			}
+		}()
+	}
+
+	// Servidor TCP: bloquea hasta la señal de apagado
+	if err := server.Start(ctx, ":"+cfg.TCPPort, server.Options{
+		MaxFrameSize:     cfg.MaxFrameSize,
+		HandshakeTimeout: cfg.HandshakeTimeout,
+		IdleTimeout:      cfg.IdleTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+	}); err != nil {
 		logger.Error("TCP server failed", "error", err)
 	}
+	stop()
+	dctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// UDP y cámaras dejan de encolar (mismo plazo que el drenado)
+	stopped := make(chan struct{})
+	go func() {
+		listeners.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-dctx.Done():
+		logger.Warn("listeners did not stop before the shutdown deadline")
+	}
+
+	// Terminar de procesar y reenviar lo ya confirmado, con plazo
+	logger.Info("shutting down: draining in-flight frames", "timeout", cfg.ShutdownTimeout)
+	drainErr := dispatcher.Drain(dctx)
+	if drainErr != nil {
+		logger.Warn("drain deadline exceeded", "error", drainErr)
+	}
+
+	server.CloseConnections()
+	// Con workers todavía corriendo Redis queda abierto: el proceso sale igual
+	if drainErr == nil {
+		if err := store.Close(); err != nil {
+			logger.Warn("redis close", "error", err)
+		}
+	}
+	logger.Info("codec-svr stopped")
 }
@@ -17,13 +17,50 @@ type GPSData struct {
 	Speed      int     `json:"speed"`
 }
 
+// GenerationType indica por qué se generó el record (sólo Codec 16).
+type GenerationType uint8
+
+const (
+	GenOnExit     GenerationType = 0
+	GenOnEntrance GenerationType = 1
+	GenOnBoth     GenerationType = 2
+	GenReserved   GenerationType = 3
+	GenHysteresis GenerationType = 4
+	GenOnChange   GenerationType = 5
+	GenEventual   GenerationType = 6
+	GenPeriodical GenerationType = 7
+)
+
+func (g GenerationType) String() string {
+	switch g {
+	case GenOnExit:
+		return "on_exit"
+	case GenOnEntrance:
+		return "on_entrance"
+	case GenOnBoth:
+		return "on_both"
+	case GenReserved:
+		return "reserved"
+	case GenHysteresis:
+		return "hysteresis"
+	case GenOnChange:
+		return "on_change"
+	case GenEventual:
+		return "eventual"
+	case GenPeriodical:
+		return "periodical"
+	}
+	return "unknown"
+}
+
 type AVLRecord struct {
-	Timestamp time.Time         `json:"timestamp"`
-	Priority  int               `json:"priority"`
-	GPS       GPSData           `json:"gps"`
-	EventIOID int               `json:"event_io_id"`
-	TotalIO   int               `json:"total_io"`
-	IO        map[uint16]IOItem `json:"io"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Priority   int               `json:"priority"`
+	GPS        GPSData           `json:"gps"`
+	EventIOID  int               `json:"event_io_id"`
+	Generation *GenerationType   `json:"generation,omitempty"` // nil salvo en Codec 16
+	TotalIO    int               `json:"total_io"`
+	IO         map[uint16]IOItem `json:"io"`
 }
 
 type AvlPacket struct {
@@ -35,3 +72,8 @@ type AvlPacket struct {
 	Qty2     uint8       `json:"qty2"`
 	CRC      uint32      `json:"crc"`
 }
+
+// IsBatch indica si el paquete trae más de un record (datos de buffer).
+func (p AvlPacket) IsBatch() bool {
+	return p.Qty1 > 1
+}
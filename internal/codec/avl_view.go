@@ -0,0 +1,365 @@
+package codec
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------
+// Decodificación sin reservas de memoria.
+//
+// DecodeAVLView recorre el AVL data y llena un PacketView reutilizable:
+// cada RecordView guarda los campos fijos del record y un slice (sin
+// copiar) de su bloque de IO dentro del frame. Los IO se leen bajo
+// demanda con EachIO, que no crea mapas. Los PacketView salen de un
+// sync.Pool (AcquirePacketView / ReleasePacketView).
+//
+// OJO: un RecordView (y los raw que entrega EachIO) aliasan el frame;
+// sólo son válidos mientras el frame no se reutilice.
+// ---------------------------------------------------------------
+
+// RecordView es un record AVL decodificado sin copiar sus IO.
+type RecordView struct {
+	TimestampMs int64
+	Priority    uint8
+	Longitude   int32 // grados * 1e7
+	Latitude    int32 // grados * 1e7
+	Altitude    uint16
+	Angle       uint16
+	Satellites  uint8
+	Speed       uint16
+	EventIOID   uint16
+	Generation  int16 // -1 salvo en Codec 16
+	TotalIO     uint16
+
+	codecID uint8
+	io      []byte // bloque IO del record (desde el primer contador hasta el último valor)
+	gsm     gsm7   // sólo Codec 7: datos GSM del elemento GPS
+}
+
+// PacketView agrupa los records de un AVL data. Reutilizable vía pool.
+type PacketView struct {
+	CodecID uint8
+	Qty1    uint8
+	Qty2    uint8
+	Records []RecordView
+}
+
+var packetViewPool = sync.Pool{
+	New: func() any { return &PacketView{Records: make([]RecordView, 0, 32)} },
+}
+
+// IsBatch indica si el paquete trae más de un record (datos de buffer).
+func (p *PacketView) IsBatch() bool {
+	return p.Qty1 > 1
+}
+
+// Packet copia el view a un AvlPacket tipado (ver RecordView.Record).
+func (p *PacketView) Packet() AvlPacket {
+	pkt := AvlPacket{
+		CodecID: p.CodecID,
+		Qty1:    p.Qty1,
+		Qty2:    p.Qty2,
+		Records: make([]AVLRecord, len(p.Records)),
+	}
+	for i := range p.Records {
+		pkt.Records[i] = p.Records[i].Record()
+	}
+	return pkt
+}
+
+// AcquirePacketView toma un PacketView vacío del pool.
+func AcquirePacketView() *PacketView {
+	v := packetViewPool.Get().(*PacketView)
+	v.CodecID, v.Qty1, v.Qty2 = 0, 0, 0
+	v.Records = v.Records[:0]
+	return v
+}
+
+// ReleasePacketView devuelve el PacketView al pool. No usarlo después.
+func ReleasePacketView(v *PacketView) {
+	if v == nil || cap(v.Records) > 1024 {
+		return
+	}
+	for i := range v.Records {
+		v.Records[i].io = nil // no retener el frame
+	}
+	packetViewPool.Put(v)
+}
+
+// IOVisitor recibe cada IO de un record. raw sólo viene en X-bytes (8E) y
+// aliasa el frame. Devolver false corta el recorrido.
+type IOVisitor func(id uint16, size int, val uint64, raw []byte) bool
+
+// ---------------------------------------------------------------
+
+// cursor lee big-endian con control de límites; el primer error se queda
+// pegado y las lecturas siguientes devuelven cero.
+type cursor struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (c *cursor) need(n int, what string) bool {
+	if c.err != nil {
+		return false
+	}
+	if c.off+n > len(c.b) {
+		c.err = decodeErr(KindTruncated, 0, c.off, -1, "oob %s", what)
+		return false
+	}
+	return true
+}
+
+// failAt completa el error pegado con el codec y el record en curso.
+func (c *cursor) failAt(codec uint8, rec int) error {
+	if de, ok := c.err.(*DecodeError); ok {
+		de.Codec, de.Record = codec, rec
+	}
+	return c.err
+}
+
+func (c *cursor) u8() uint8 {
+	if !c.need(1, "u8") {
+		return 0
+	}
+	v := c.b[c.off]
+	c.off++
+	return v
+}
+
+func (c *cursor) u16() uint16 {
+	if !c.need(2, "u16") {
+		return 0
+	}
+	v := binary.BigEndian.Uint16(c.b[c.off:])
+	c.off += 2
+	return v
+}
+
+func (c *cursor) u32() uint32 {
+	if !c.need(4, "u32") {
+		return 0
+	}
+	v := binary.BigEndian.Uint32(c.b[c.off:])
+	c.off += 4
+	return v
+}
+
+func (c *cursor) u64() uint64 {
+	if !c.need(8, "u64") {
+		return 0
+	}
+	v := binary.BigEndian.Uint64(c.b[c.off:])
+	c.off += 8
+	return v
+}
+
+func (c *cursor) bytes(n int, what string) []byte {
+	if !c.need(n, what) {
+		return nil
+	}
+	v := c.b[c.off : c.off+n]
+	c.off += n
+	return v
+}
+
+// IDs de IO: uint16 en 8E y 16, uint8 en Codec 8
+func (c *cursor) ioID(codec uint8) uint16 {
+	if codec != CodecID8 {
+		return c.u16()
+	}
+	return uint16(c.u8())
+}
+
+// Contadores de IO: uint16 sólo en 8E
+func (c *cursor) ioCount(codec uint8) uint16 {
+	if codec == CodecID8E {
+		return c.u16()
+	}
+	return uint16(c.u8())
+}
+
+// walkIO recorre un bloque de IO; con fn == nil sólo avanza el cursor
+// (para encontrar dónde empieza el record siguiente).
+func walkIO(c *cursor, codec uint8, fn IOVisitor) {
+	for _, size := range [4]int{1, 2, 4, 8} {
+		cnt := int(c.ioCount(codec))
+		for i := 0; i < cnt && c.err == nil; i++ {
+			id := c.ioID(codec)
+			var v uint64
+			switch size {
+			case 1:
+				v = uint64(c.u8())
+			case 2:
+				v = uint64(c.u16())
+			case 4:
+				v = uint64(c.u32())
+			case 8:
+				v = c.u64()
+			}
+			if c.err == nil && fn != nil && !fn(id, size, v, nil) {
+				return
+			}
+		}
+	}
+
+	// X-bytes values (sólo existen en 8E)
+	if codec != CodecID8E {
+		return
+	}
+	cnx := int(c.u16())
+	for i := 0; i < cnx && c.err == nil; i++ {
+		id := c.ioID(codec)
+		l := int(c.u16()) // longitud del payload del ítem
+		raw := c.bytes(l, "x-bytes payload")
+		if c.err == nil && fn != nil && !fn(id, l, 0, raw) {
+			return
+		}
+	}
+}
+
+// ---------------------------------------------------------------
+
+// DecodeAVLView decodifica el AVL data (desde Codec ID hasta Qty2) en v,
+// reutilizando su slice de records.
+func DecodeAVLView(data []byte, v *PacketView) error {
+	v.Records = v.Records[:0]
+	if len(data) < 3 {
+		return decodeErr(KindBadLength, 0, len(data), -1, "avl data too short")
+	}
+
+	c := cursor{b: data}
+	codec := c.u8()
+	if !IsAVLCodec(codec) {
+		return decodeErr(KindUnknownCodec, codec, 0, -1, "codec 0x%X not an AVL codec", codec)
+	}
+	v.CodecID = codec
+
+	n1 := int(c.u8()) // Number of Data 1 (records)
+	if n1 <= 0 {
+		return decodeErr(KindNoRecords, codec, 1, -1, "no records")
+	}
+	v.Qty1 = uint8(n1)
+
+	for r := 0; r < n1; r++ {
+		var rec RecordView
+		rec.codecID = codec
+
+		if codec == CodecID7 {
+			decodeRecord7(&c, &rec)
+			if c.err != nil {
+				return c.failAt(codec, r)
+			}
+			v.Records = append(v.Records, rec)
+			continue
+		}
+
+		// Timestamp (8B, ms) + Priority (1B)
+		rec.TimestampMs = int64(c.u64())
+		rec.Priority = c.u8()
+
+		// GPS: lon(4), lat(4), alt(2), ang(2), sats(1), spd(2)
+		rec.Longitude = int32(c.u32())
+		rec.Latitude = int32(c.u32())
+		rec.Altitude = c.u16()
+		rec.Angle = c.u16()
+		rec.Satellites = c.u8()
+		rec.Speed = c.u16()
+
+		// IO header: event_io_id, [generation type], total_io
+		rec.EventIOID = c.ioID(codec)
+		rec.Generation = -1
+		if codec == CodecID16 {
+			rec.Generation = int16(c.u8())
+		}
+		rec.TotalIO = c.ioCount(codec)
+
+		start := c.off
+		walkIO(&c, codec, nil)
+		if c.err != nil {
+			return c.failAt(codec, r)
+		}
+		rec.io = data[start:c.off]
+
+		v.Records = append(v.Records, rec)
+	}
+
+	// Number of Data 2
+	if c.off >= len(data) {
+		return decodeErr(KindTruncated, codec, c.off, -1, "missing qty2")
+	}
+	n2 := int(c.u8())
+	if n2 != n1 {
+		return decodeErr(KindCountMismatch, codec, c.off-1, -1, "n2 (%d) != n1 (%d)", n2, n1)
+	}
+	v.Qty2 = uint8(n2)
+	return nil
+}
+
+// EachIO recorre los IO del record sin reservar memoria.
+func (r *RecordView) EachIO(fn IOVisitor) error {
+	c := cursor{b: r.io}
+	if r.codecID == CodecID7 {
+		if r.gsm.each(fn) {
+			walkIO7(&c, r.gsm.globalMask, fn)
+		}
+		return c.err
+	}
+	walkIO(&c, r.codecID, fn)
+	return c.err
+}
+
+// Time devuelve el timestamp del record en UTC.
+func (r *RecordView) Time() time.Time {
+	return time.UnixMilli(r.TimestampMs).UTC()
+}
+
+// GPS devuelve el elemento GPS del record en grados.
+func (r *RecordView) GPS() GPSData {
+	return GPSData{
+		Longitude:  float64(r.Longitude) / 1e7,
+		Latitude:   float64(r.Latitude) / 1e7,
+		Altitude:   int(r.Altitude),
+		Angle:      int(r.Angle),
+		Satellites: int(r.Satellites),
+		Speed:      int(r.Speed),
+	}
+}
+
+// GenerationType devuelve el generation type del record (sólo Codec 16).
+func (r *RecordView) GenerationType() (GenerationType, bool) {
+	if r.Generation < 0 {
+		return 0, false
+	}
+	return GenerationType(r.Generation), true
+}
+
+// Record copia el view a un AVLRecord tipado (con su mapa de IO propio).
+func (r *RecordView) Record() AVLRecord {
+	rec := AVLRecord{
+		Timestamp: r.Time(),
+		Priority:  int(r.Priority),
+		GPS:       r.GPS(),
+		EventIOID: int(r.EventIOID),
+		TotalIO:   int(r.TotalIO),
+		IO:        make(map[uint16]IOItem, r.TotalIO),
+	}
+	if gen, ok := r.GenerationType(); ok {
+		rec.Generation = &gen
+	}
+	r.EachIO(func(id uint16, size int, val uint64, raw []byte) bool {
+		if raw != nil {
+			// Copia: el AVLRecord sobrevive al frame
+			cp := make([]byte, len(raw))
+			copy(cp, raw)
+			rec.IO[id] = IOItem{Size: size, Raw: cp}
+			return true
+		}
+		rec.IO[id] = IOItem{Size: size, Val: val}
+		return true
+	})
+	return rec
+}
@@ -0,0 +1,176 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// benchPacket arma n records con un set de IO típico de un FMB/FMC.
+func benchPacket(id uint8, n int) AvlPacket {
+	base := time.Unix(1700000000, 0).UTC()
+	recs := make([]AVLRecord, n)
+	for i := range recs {
+		io := map[uint16]IOItem{
+			239: {Size: 1, Val: 1},     // ignición
+			240: {Size: 1, Val: 1},     // movimiento
+			80:  {Size: 1, Val: 0},     // data mode
+			21:  {Size: 1, Val: 4},     // señal GSM
+			200: {Size: 1, Val: 0},     // sleep mode
+			69:  {Size: 1, Val: 1},     // GNSS status
+			181: {Size: 2, Val: 12},    // PDOP
+			182: {Size: 2, Val: 8},     // HDOP
+			66:  {Size: 2, Val: 12450}, // voltaje externo
+			67:  {Size: 2, Val: 4100},  // batería
+			205: {Size: 2, Val: 1234},  // cell ID
+			16:  {Size: 4, Val: 100000},
+			199: {Size: 4, Val: 1200},
+			11:  {Size: 8, Val: 0x3839353230313030},
+		}
+		if id == CodecID8E {
+			io[264] = IOItem{Size: 8, Raw: []byte("AB123456")}
+		}
+		recs[i] = testRecord(id, io)
+		recs[i].Timestamp = base.Add(time.Duration(i) * time.Second)
+	}
+	return AvlPacket{CodecID: id, Records: recs}
+}
+
+func benchFrame(tb testing.TB, id uint8, n int) []byte {
+	tb.Helper()
+	frame, err := EncodeAVL(benchPacket(id, n))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return frame
+}
+
+func TestDecodeAVLViewMatchesParseAVL(t *testing.T) {
+	for _, id := range []uint8{CodecID8, CodecID8E, CodecID16} {
+		frame := benchFrame(t, id, 5)
+		pkt, err := ParseAVL(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v := AcquirePacketView()
+		if err := DecodeAVLFrame(frame, v); err != nil {
+			t.Fatal(err)
+		}
+		if len(v.Records) != len(pkt.Records) || v.Qty1 != pkt.Qty1 || v.Qty2 != pkt.Qty2 {
+			t.Fatalf("codec 0x%02X: view has %d records", id, len(v.Records))
+		}
+		for i := range v.Records {
+			r := &v.Records[i]
+			want := pkt.Records[i]
+			if !r.Time().Equal(want.Timestamp) || r.GPS() != want.GPS || int(r.EventIOID) != want.EventIOID {
+				t.Fatalf("codec 0x%02X record %d: header differs", id, i)
+			}
+			got := map[uint16]IOItem{}
+			if err := r.EachIO(func(id uint16, size int, val uint64, raw []byte) bool {
+				got[id] = IOItem{Size: size, Val: val, Raw: raw}
+				return true
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want.IO) {
+				t.Fatalf("codec 0x%02X record %d: IO differs\n view  %v\n typed %v", id, i, got, want.IO)
+			}
+		}
+		ReleasePacketView(v)
+	}
+}
+
+func TestDecodeAVLViewNoAllocs(t *testing.T) {
+	frame := benchFrame(t, CodecID8E, 20)
+	var sum uint64
+	visit := func(id uint16, size int, val uint64, raw []byte) bool {
+		sum += val + uint64(len(raw))
+		return true
+	}
+
+	// Calentar el pool
+	ReleasePacketView(AcquirePacketView())
+
+	allocs := testing.AllocsPerRun(100, func() {
+		v := AcquirePacketView()
+		if err := DecodeAVLFrame(frame, v); err != nil {
+			t.Fatal(err)
+		}
+		for i := range v.Records {
+			v.Records[i].EachIO(visit)
+		}
+		ReleasePacketView(v)
+	})
+	if allocs != 0 {
+		t.Fatalf("DecodeAVLFrame+EachIO allocates %.1f times per frame", allocs)
+	}
+}
+
+// BenchmarkDecodeAVL compara, por frame 8E, el camino anterior (hex del
+// frame + ParseCodec8E a map[string]interface{} + IO por reflexión), el
+// tipado (ParseAVL, un mapa por record) y el de views del pool.
+//
+//	go test -bench DecodeAVL -benchmem ./internal/codec/
+func BenchmarkDecodeAVL(b *testing.B) {
+	for _, n := range []int{1, 20} {
+		frame := benchFrame(b, CodecID8E, n)
+		data := frame[8 : 8+int(binary.BigEndian.Uint32(frame[4:8]))]
+		name := "records=" + strconv.Itoa(n)
+
+		b.Run("legacy/"+name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = hex.EncodeToString(frame)
+				parsed, err := legacyParseCodec8E(frame)
+				if err != nil {
+					b.Fatal(err)
+				}
+				_ = legacyExtractIOItems(parsed["io"])
+			}
+		})
+
+		b.Run("ParseAVL/"+name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseAVL(frame); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run("view/"+name, func(b *testing.B) {
+			var sum uint64
+			visit := func(id uint16, size int, val uint64, raw []byte) bool {
+				sum += val
+				return true
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				v := AcquirePacketView()
+				if err := DecodeAVLFrame(frame, v); err != nil {
+					b.Fatal(err)
+				}
+				for r := range v.Records {
+					v.Records[r].EachIO(visit)
+				}
+				ReleasePacketView(v)
+			}
+			_ = sum
+		})
+
+		b.Run("view-data/"+name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				v := AcquirePacketView()
+				if err := DecodeAVLView(data, v); err != nil {
+					b.Fatal(err)
+				}
+				ReleasePacketView(v)
+			}
+		})
+	}
+}
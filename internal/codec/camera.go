@@ -0,0 +1,141 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ---------------------------------------------------------------
+// Protocolo de transferencia de archivos de cámaras (DualCam / ADAS).
+// Va por un socket TCP propio, sin preámbulo ni CRC de frame; cada
+// paquete empieza con el comando (2B):
+//
+//	0x0000 Init      equipo→srv  IMEI(8B BCD) | archivos disponibles(1B)
+//	0x0001 Start     equipo→srv  total de paquetes del archivo(4B)
+//	0x0002 Resume    srv→equipo  paquete desde el que (re)enviar(4B)
+//	0x0003 Sync      equipo→srv  índice del próximo paquete(4B)
+//	0x0004 Data      equipo→srv  len(2B) | datos | CRC-16/CCITT(2B)
+//	0x0005 Complete  srv→equipo  fin de la sesión
+//	0x0008 Request   srv→equipo  len(2B) | ruta ASCII ("%photof", ...)
+// ---------------------------------------------------------------
+
+const (
+	CamInit     uint16 = 0x0000
+	CamStart    uint16 = 0x0001
+	CamResume   uint16 = 0x0002
+	CamSync     uint16 = 0x0003
+	CamData     uint16 = 0x0004
+	CamComplete uint16 = 0x0005
+	CamRequest  uint16 = 0x0008
+)
+
+// Bits del byte de archivos disponibles del Init.
+const (
+	CamHasFrontPhoto = 0x01
+	CamHasRearPhoto  = 0x02
+	CamHasFrontVideo = 0x04
+	CamHasRearVideo  = 0x08
+)
+
+// CamMaxChunk acota el largo de un paquete Data.
+const CamMaxChunk = 8 * 1024
+
+// CamPacket es un paquete recibido del equipo. Sólo vienen cargados los
+// campos del comando correspondiente.
+type CamPacket struct {
+	Cmd    uint16
+	IMEI   string // Init
+	Files  uint8  // Init
+	Total  uint32 // Start
+	Offset uint32 // Sync
+	Data   []byte // Data
+	CRCOK  bool   // Data
+}
+
+// ReadCamPacket lee un paquete del equipo.
+func ReadCamPacket(r *bufio.Reader) (CamPacket, error) {
+	var p CamPacket
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return p, err
+	}
+	p.Cmd = binary.BigEndian.Uint16(hdr[:])
+
+	switch p.Cmd {
+	case CamInit:
+		var b [9]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return p, err
+		}
+		p.IMEI = decodeIMEI(b[:8])
+		p.Files = b[8]
+
+	case CamStart, CamSync:
+		var b [4]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return p, err
+		}
+		if p.Cmd == CamStart {
+			p.Total = binary.BigEndian.Uint32(b[:])
+		} else {
+			p.Offset = binary.BigEndian.Uint32(b[:])
+		}
+
+	case CamData:
+		var l [2]byte
+		if _, err := io.ReadFull(r, l[:]); err != nil {
+			return p, err
+		}
+		n := int(binary.BigEndian.Uint16(l[:]))
+		if n > CamMaxChunk {
+			return p, fmt.Errorf("camera chunk %d exceeds %d", n, CamMaxChunk)
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return p, err
+		}
+		p.Data = buf[:n]
+		p.CRCOK = binary.BigEndian.Uint16(buf[n:]) == crc16CCITT(p.Data)
+
+	default:
+		return p, fmt.Errorf("unknown camera command 0x%04X", p.Cmd)
+	}
+	return p, nil
+}
+
+// BuildCamRequest pide al equipo el archivo path.
+func BuildCamRequest(path string) []byte {
+	out := make([]byte, 0, 4+len(path))
+	out = binary.BigEndian.AppendUint16(out, CamRequest)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(path)))
+	return append(out, path...)
+}
+
+// BuildCamResume pide seguir (o reenviar) desde el paquete offset.
+func BuildCamResume(offset uint32) []byte {
+	out := binary.BigEndian.AppendUint16(nil, CamResume)
+	return binary.BigEndian.AppendUint32(out, offset)
+}
+
+// BuildCamComplete cierra la sesión de transferencia.
+func BuildCamComplete() []byte {
+	return binary.BigEndian.AppendUint16(nil, CamComplete)
+}
+
+// crc16CCITT: poly 0x1021, init 0x0000 (XMODEM), el de los paquetes Data.
+func crc16CCITT(b []byte) uint16 {
+	var crc uint16
+	for _, v := range b {
+		crc ^= uint16(v) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,108 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCRC16CCITT(t *testing.T) {
+	// Vector de verificación de CRC-16/XMODEM
+	for _, tc := range []struct {
+		in   string
+		want uint16
+	}{
+		{"123456789", 0x31C3},
+		{"", 0x0000},
+		{"A", 0x58E5},
+	} {
+		if got := crc16CCITT([]byte(tc.in)); got != tc.want {
+			t.Errorf("crc16CCITT(%q) = 0x%04X, want 0x%04X", tc.in, got, tc.want)
+		}
+	}
+}
+
+func camData(payload []byte, crc uint16) []byte {
+	b := binary.BigEndian.AppendUint16(nil, CamData)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	b = append(b, payload...)
+	return binary.BigEndian.AppendUint16(b, crc)
+}
+
+func TestReadCamPacket(t *testing.T) {
+	chunk := []byte("123456789")
+	stream := cat(
+		mustHex(t, "0000"+"0352093081452251"+"05"), // Init: IMEI BCD + foto y video frontal
+		mustHex(t, "0001"+"0000012C"),              // Start: 300 paquetes
+		mustHex(t, "0003"+"00000040"),              // Sync: desde el 64
+		camData(chunk, 0x31C3),
+		camData(chunk, 0x31C4),
+		camData(nil, 0),
+	)
+	r := bufio.NewReader(bytes.NewReader(stream))
+
+	want := []CamPacket{
+		{Cmd: CamInit, IMEI: "352093081452251", Files: CamHasFrontPhoto | CamHasFrontVideo},
+		{Cmd: CamStart, Total: 300},
+		{Cmd: CamSync, Offset: 64},
+		{Cmd: CamData, Data: chunk, CRCOK: true},
+		{Cmd: CamData, Data: chunk, CRCOK: false},
+		{Cmd: CamData, Data: []byte{}, CRCOK: true},
+	}
+	for i, w := range want {
+		p, err := ReadCamPacket(r)
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if p.Cmd != w.Cmd || p.IMEI != w.IMEI || p.Files != w.Files || p.Total != w.Total ||
+			p.Offset != w.Offset || !bytes.Equal(p.Data, w.Data) || p.CRCOK != w.CRCOK {
+			t.Fatalf("packet %d = %+v, want %+v", i, p, w)
+		}
+	}
+	if _, err := ReadCamPacket(r); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestReadCamPacketErrors(t *testing.T) {
+	oversize := binary.BigEndian.AppendUint16(mustHex(t, "0004"), CamMaxChunk+1)
+
+	for _, tc := range []struct {
+		name string
+		raw  []byte
+		eof  bool // truncado → io.ErrUnexpectedEOF
+	}{
+		{"unknown command", mustHex(t, "0007"), false},
+		{"server-only command", mustHex(t, "00020000000A"), false},
+		{"oversize chunk", oversize, false},
+		{"truncated init", mustHex(t, "00000352093081"), true},
+		{"truncated start", mustHex(t, "000100"), true},
+		{"truncated data", cat(mustHex(t, "00040009"), []byte("1234")), true},
+		{"data without crc", cat(mustHex(t, "00040009"), []byte("123456789")), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ReadCamPacket(bufio.NewReader(bytes.NewReader(tc.raw)))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if got := errors.Is(err, io.ErrUnexpectedEOF); got != tc.eof {
+				t.Fatalf("err = %v", err)
+			}
+		})
+	}
+}
+
+func TestBuildCamPackets(t *testing.T) {
+	if got := BuildCamRequest("%photof"); !bytes.Equal(got, cat(mustHex(t, "00080007"), []byte("%photof"))) {
+		t.Errorf("request = % X", got)
+	}
+	if got := BuildCamResume(64); !bytes.Equal(got, mustHex(t, "000200000040")) {
+		t.Errorf("resume = % X", got)
+	}
+	if got := BuildCamComplete(); !bytes.Equal(got, mustHex(t, "0005")) {
+		t.Errorf("complete = % X", got)
+	}
+}
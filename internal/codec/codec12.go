@@ -5,20 +5,19 @@ import (
 	"errors"
 )
 
-func crc16IBM(b []byte) uint16 {
-	var crc uint16
-	for _, v := range b {
-		crc ^= uint16(v)
-		for i := 0; i < 8; i++ {
-			if (crc & 1) == 1 {
-				crc = (crc >> 1) ^ 0xA001
-			} else {
-				crc >>= 1
-			}
-		}
-	}
-	return crc
-}
+// Codec IDs del canal de comandos/mensajes.
+const (
+	CodecID12 uint8 = 0x0C
+	CodecID13 uint8 = 0x0D
+	CodecID14 uint8 = 0x0E
+)
+
+// Tipos de mensaje en Codec 12/13/14.
+const (
+	CmdTypeCommand  uint8 = 0x05
+	CmdTypeResponse uint8 = 0x06
+	CmdTypeNack     uint8 = 0x11 // Codec 14: IMEI no coincide
+)
 
 // BuildCodec12 arma un comando Codec 12 (Type=0x05) con el texto ASCII cmd (p.ej. "getver").
 // Frame = 00000000 | dataSize(4B) | payload | crc(4B)
@@ -28,13 +27,34 @@ func BuildCodec12(cmd string) []byte {
 		// Teltonika espera un texto; evita mandar vacío.
 		cmd = "getver"
 	}
-	putU32 := func(n uint32) []byte { return []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)} }
-
 	cmdBytes := []byte(cmd)
-	payload := append([]byte{0x0C, 0x01, 0x05}, putU32(uint32(len(cmdBytes)))...)
+	payload := append([]byte{CodecID12, 0x01, CmdTypeCommand}, putU32(uint32(len(cmdBytes)))...)
 	payload = append(payload, cmdBytes...)
 	payload = append(payload, 0x01) // Qty2
 
+	return wrapFrame(payload)
+}
+
+// ParseCodec12Response parsea una respuesta Codec12 (Type=0x06) y devuelve el texto ASCII.
+func ParseCodec12Response(frame []byte) (string, error) {
+	typ, body, err := parseCommandFrame(frame, CodecID12)
+	if err != nil {
+		return "", err
+	}
+	// Type debe ser 0x06 (response)
+	if typ != CmdTypeResponse {
+		return "", errors.New("not a response type")
+	}
+	return string(body), nil // ASCII
+}
+
+// ------------------------- helpers -------------------------
+
+func putU32(n uint32) []byte { return []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)} }
+
+// wrapFrame envuelve un payload (desde Codec ID hasta Qty2) con
+// preámbulo, dataSize y CRC16 en 4B (00 00 hi lo).
+func wrapFrame(payload []byte) []byte {
 	dataSize := uint32(len(payload))
 	crc := crc16IBM(payload)
 
@@ -46,45 +66,39 @@ func BuildCodec12(cmd string) []byte {
 	return out
 }
 
-// ParseCodec12Response parsea una respuesta Codec12 (Type=0x06) y devuelve el texto ASCII.
-func ParseCodec12Response(frame []byte) (string, error) {
+// parseCommandFrame valida un frame Codec 12/13/14 y devuelve el tipo
+// de mensaje y el cuerpo (los respSize bytes tras el campo de tamaño).
+// payload = codec | 0x01 | type | size(4B) | body | 0x01
+func parseCommandFrame(frame []byte, codecID uint8) (uint8, []byte, error) {
 	if len(frame) < 12 {
-		return "", errors.New("frame too short")
+		return 0, nil, errors.New("frame too short")
 	}
 	// dataSize
 	dataLen := int(binary.BigEndian.Uint32(frame[4:8]))
 	end := 8 + dataLen
 	if end+4 > len(frame) {
-		return "", errors.New("incomplete frame")
+		return 0, nil, errors.New("incomplete frame")
 	}
 	payload := frame[8:end]
-	crcGot := binary.BigEndian.Uint32(frame[end : end+4]) // 00 00 hi lo
 
 	// Validaciones de payload
-	if len(payload) < 8 || payload[0] != 0x0C {
-		return "", errors.New("not codec 0x0C")
-	}
-	// Type debe ser 0x06 (response)
-	if payload[2] != 0x06 {
-		return "", errors.New("not a response type")
+	if len(payload) < 8 || payload[0] != codecID {
+		return 0, nil, errors.New("unexpected codec id")
 	}
 	respSize := int(binary.BigEndian.Uint32(payload[3:7]))
 	if 7+respSize+1 > len(payload) {
-		return "", errors.New("bad resp size")
+		return 0, nil, errors.New("bad resp size")
 	}
 	// Qty2 al final del payload
 	qty2 := payload[7+respSize]
 	if qty2 != 0x01 {
-		return "", errors.New("bad qty2")
+		return 0, nil, errors.New("bad qty2")
 	}
 
 	// CRC16/IBM sobre payload; va en 4 bytes: 00 00 hi lo
-	crcCalc := uint32(crc16IBM(payload))
-	crcCalc = uint32(uint16(crcCalc))      // asegurar 16b
-	if crcGot != uint32(uint16(crcCalc)) { // tolerante a los 00 00 altos
-		return "", errors.New("crc mismatch")
+	if err := VerifyFrameCRC(frame); err != nil {
+		return 0, nil, err
 	}
 
-	text := string(payload[7 : 7+respSize]) // ASCII
-	return text, nil
+	return payload[2], payload[7 : 7+respSize], nil
 }
@@ -0,0 +1,35 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Codec13Message es un mensaje unidireccional del equipo (p.ej. datos del
+// puerto serie) con el timestamp en que el equipo lo generó.
+type Codec13Message struct {
+	Timestamp time.Time
+	Text      string
+}
+
+// ParseCodec13 parsea un frame Codec 13 (Type=0x05, sin respuesta del servidor).
+// payload = 0x0D | 0x01 | 0x05 | size(4B) | timestamp(4B, s) | mensaje | 0x01
+// size incluye los 4 bytes del timestamp.
+func ParseCodec13(frame []byte) (Codec13Message, error) {
+	var msg Codec13Message
+	typ, body, err := parseCommandFrame(frame, CodecID13)
+	if err != nil {
+		return msg, err
+	}
+	if typ != CmdTypeCommand {
+		return msg, fmt.Errorf("codec13: unexpected type 0x%02X", typ)
+	}
+	if len(body) < 4 {
+		return msg, errors.New("codec13: missing timestamp")
+	}
+	msg.Timestamp = time.Unix(int64(binary.BigEndian.Uint32(body[:4])), 0).UTC()
+	msg.Text = string(body[4:])
+	return msg, nil
+}
@@ -0,0 +1,76 @@
+package codec
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Codec14Response es la respuesta de un equipo a un comando Codec 14.
+// Ack=false corresponde al nACK (Type=0x11): el IMEI del comando no es el suyo.
+type Codec14Response struct {
+	IMEI string
+	Text string
+	Ack  bool
+}
+
+// BuildCodec14 arma un comando Codec 14 dirigido a un IMEI concreto.
+// payload = 0x0E | 0x01 | 0x05 | size(4B) | IMEI(8B) | cmd | 0x01
+// size incluye los 8 bytes del IMEI.
+func BuildCodec14(imei, cmd string) ([]byte, error) {
+	if len(cmd) == 0 {
+		cmd = "getver"
+	}
+	imeiBytes, err := encodeIMEI(imei)
+	if err != nil {
+		return nil, err
+	}
+	body := append(imeiBytes, []byte(cmd)...)
+
+	payload := append([]byte{CodecID14, 0x01, CmdTypeCommand}, putU32(uint32(len(body)))...)
+	payload = append(payload, body...)
+	payload = append(payload, 0x01) // Qty2
+
+	return wrapFrame(payload), nil
+}
+
+// ParseCodec14Response parsea una respuesta Codec 14 (ACK 0x06 o nACK 0x11).
+func ParseCodec14Response(frame []byte) (Codec14Response, error) {
+	var res Codec14Response
+	typ, body, err := parseCommandFrame(frame, CodecID14)
+	if err != nil {
+		return res, err
+	}
+	if typ != CmdTypeResponse && typ != CmdTypeNack {
+		return res, fmt.Errorf("codec14: unexpected type 0x%02X", typ)
+	}
+	if len(body) < 8 {
+		return res, errors.New("codec14: missing imei")
+	}
+	res.IMEI = decodeIMEI(body[:8])
+	res.Ack = typ == CmdTypeResponse
+	res.Text = string(body[8:])
+	return res, nil
+}
+
+// encodeIMEI codifica el IMEI como 8 bytes "hex" (p.ej. 352093081452251 -> 03 52 09 30 81 45 22 51).
+func encodeIMEI(imei string) ([]byte, error) {
+	if len(imei) == 0 || len(imei) > 16 {
+		return nil, fmt.Errorf("invalid imei %q", imei)
+	}
+	for _, r := range imei {
+		if r < '0' || r > '9' {
+			return nil, fmt.Errorf("invalid imei %q", imei)
+		}
+	}
+	return hex.DecodeString(strings.Repeat("0", 16-len(imei)) + imei)
+}
+
+func decodeIMEI(b []byte) string {
+	s := strings.TrimLeft(hex.EncodeToString(b), "0")
+	if s == "" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Codec 15: datos de terceros por RS232 (FMX6 en modos "TCP binary/ASCII").
+const (
+	CodecID15     uint8 = 0x0F
+	CmdTypeSerial uint8 = 0x0B
+)
+
+// Codec15Message es un bloque de datos del puerto serie con su timestamp de equipo.
+type Codec15Message struct {
+	Timestamp time.Time
+	IMEI      string
+	Data      []byte
+}
+
+// ParseCodec15 parsea un frame Codec 15 (sin respuesta del servidor).
+// payload = 0x0F | 0x01 | 0x0B | size(4B) | timestamp(4B, s) | IMEI(8B) | datos | 0x01
+// size incluye los 4 bytes del timestamp y los 8 del IMEI.
+func ParseCodec15(frame []byte) (Codec15Message, error) {
+	var msg Codec15Message
+	typ, body, err := parseCommandFrame(frame, CodecID15)
+	if err != nil {
+		return msg, err
+	}
+	if typ != CmdTypeSerial {
+		return msg, fmt.Errorf("codec15: unexpected type 0x%02X", typ)
+	}
+	if len(body) < 12 {
+		return msg, errors.New("codec15: missing timestamp/imei")
+	}
+	msg.Timestamp = time.Unix(int64(binary.BigEndian.Uint32(body[:4])), 0).UTC()
+	msg.IMEI = decodeIMEI(body[4:12])
+	msg.Data = make([]byte, len(body)-12)
+	copy(msg.Data, body[12:])
+	return msg, nil
+}
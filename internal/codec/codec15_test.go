@@ -0,0 +1,57 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// codec15Frame arma un frame Codec 15 con timestamp, IMEI y datos serie.
+func codec15Frame(t *testing.T, ts uint32, imei string, data []byte) []byte {
+	t.Helper()
+	body := cat(putU32(ts), mustHex(t, imei), data)
+	return wrapFrame(cat([]byte{CodecID15, 0x01, CmdTypeSerial}, putU32(uint32(len(body))), body, []byte{0x01}))
+}
+
+func TestParseCodec15(t *testing.T) {
+	// Lectura de un lector RFID en modo "TCP binary"
+	data := mustHex(t, "02303030303646354238410D0A03")
+	msg, err := ParseCodec15(codec15Frame(t, 1700000000, "0352093081452251", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1700000000, 0).UTC(); !msg.Timestamp.Equal(want) {
+		t.Fatalf("timestamp = %v", msg.Timestamp)
+	}
+	if msg.IMEI != "352093081452251" || !bytes.Equal(msg.Data, data) {
+		t.Fatalf("msg = %+v", msg)
+	}
+
+	// Sin datos: sólo timestamp + IMEI
+	msg, err = ParseCodec15(codec15Frame(t, 1700000000, "0352093081452251", nil))
+	if err != nil || len(msg.Data) != 0 {
+		t.Fatalf("empty data: %+v, %v", msg, err)
+	}
+}
+
+func TestParseCodec15Errors(t *testing.T) {
+	frame := codec15Frame(t, 1700000000, "0352093081452251", []byte("W:12.5kg"))
+	badType := append([]byte{}, frame[8:len(frame)-4]...)
+	badType[2] = CmdTypeCommand
+
+	for _, tc := range []struct {
+		name  string
+		frame []byte
+	}{
+		{"wrong type", wrapFrame(badType)},
+		{"missing imei", wrapFrame(cat([]byte{CodecID15, 0x01, CmdTypeSerial}, putU32(8), make([]byte, 8), []byte{0x01}))},
+		{"wrong codec", BuildCodec12("getver")},
+		{"incomplete", frame[:len(frame)-3]},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseCodec15(tc.frame); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
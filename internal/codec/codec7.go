@@ -0,0 +1,183 @@
+package codec
+
+import (
+	"math"
+	"time"
+)
+
+// ---------------------------------------------------------------
+// Codec 7 (GH3000). Cada record es:
+//
+//	timestamp(4B) | global mask(1B) | [elemento GPS] | [IO 1B] | [IO 2B] | [IO 4B]
+//
+// timestamp: 2 bits altos = prioridad, 30 bits bajos = segundos desde
+// 2007-01-01 00:00 UTC. La global mask dice qué bloques vienen; el
+// elemento GPS trae su propia máscara. Los grupos de IO usan contador e
+// IDs de 1 byte.
+//
+// LAC, Cell ID, señal GSM y operador vienen dentro del elemento GPS; se
+// exponen como los IO equivalentes del rango FM (206, 205, 21, 241) para
+// que el resto del pipeline no distinga el codec.
+// ---------------------------------------------------------------
+
+// Bits de la global mask.
+const (
+	mask7GPS = 0x01
+	mask7IO1 = 0x02
+	mask7IO2 = 0x04
+	mask7IO4 = 0x08
+)
+
+// epoch7Sec es el origen de los timestamps Codec 7 (2007-01-01T00:00:00Z).
+const epoch7Sec = 1167609600
+
+// Bits de la máscara del elemento GPS.
+const (
+	gps7LatLon   = 0x01
+	gps7Altitude = 0x02
+	gps7Angle    = 0x04
+	gps7Speed    = 0x08
+	gps7Sats     = 0x10
+	gps7Cell     = 0x20
+	gps7Signal   = 0x40
+	gps7Operator = 0x80
+)
+
+// IO del rango FM a los que se mapean los datos GSM del elemento GPS.
+const (
+	io7AreaCode = 206
+	io7CellID   = 205
+	io7Signal   = 21
+	io7Operator = 241
+)
+
+// gsm7 guarda las máscaras y los datos GSM de un record Codec 7 sin
+// reservar memoria; EachIO los entrega como IO normales.
+type gsm7 struct {
+	globalMask uint8
+	gpsMask    uint8
+	areaCode   uint16
+	cellID     uint16
+	signal     uint8
+	operator   uint32
+}
+
+// each entrega los IO GSM presentes; false si fn cortó el recorrido.
+func (g *gsm7) each(fn IOVisitor) bool {
+	if g.globalMask&mask7GPS == 0 {
+		return true
+	}
+	if g.gpsMask&gps7Cell != 0 {
+		if !fn(io7AreaCode, 2, uint64(g.areaCode), nil) || !fn(io7CellID, 2, uint64(g.cellID), nil) {
+			return false
+		}
+	}
+	if g.gpsMask&gps7Signal != 0 && !fn(io7Signal, 1, uint64(g.signal), nil) {
+		return false
+	}
+	if g.gpsMask&gps7Operator != 0 && !fn(io7Operator, 4, uint64(g.operator), nil) {
+		return false
+	}
+	return true
+}
+
+func (g *gsm7) count() uint16 {
+	var n uint16
+	if g.globalMask&mask7GPS == 0 {
+		return 0
+	}
+	if g.gpsMask&gps7Cell != 0 {
+		n += 2
+	}
+	if g.gpsMask&gps7Signal != 0 {
+		n++
+	}
+	if g.gpsMask&gps7Operator != 0 {
+		n++
+	}
+	return n
+}
+
+// decodeRecord7 lee un record Codec 7 en rec. Los errores quedan en c.err.
+func decodeRecord7(c *cursor, rec *RecordView) {
+	ts := c.u32()
+	rec.Priority = uint8(ts >> 30)
+	rec.TimestampMs = (epoch7Sec + int64(ts&0x3FFFFFFF)) * int64(time.Second/time.Millisecond)
+	rec.Generation = -1
+
+	g := &rec.gsm
+	g.globalMask = c.u8()
+
+	if g.globalMask&mask7GPS != 0 {
+		g.gpsMask = c.u8()
+		if g.gpsMask&gps7LatLon != 0 {
+			lat := math.Float32frombits(c.u32())
+			lon := math.Float32frombits(c.u32())
+			rec.Latitude = int32(math.Round(float64(lat) * 1e7))
+			rec.Longitude = int32(math.Round(float64(lon) * 1e7))
+		}
+		if g.gpsMask&gps7Altitude != 0 {
+			rec.Altitude = c.u16()
+		}
+		if g.gpsMask&gps7Angle != 0 {
+			rec.Angle = uint16(uint32(c.u8()) * 360 / 256)
+		}
+		if g.gpsMask&gps7Speed != 0 {
+			rec.Speed = uint16(c.u8())
+		}
+		if g.gpsMask&gps7Sats != 0 {
+			rec.Satellites = c.u8()
+		}
+		if g.gpsMask&gps7Cell != 0 {
+			g.areaCode = c.u16()
+			g.cellID = c.u16()
+		}
+		if g.gpsMask&gps7Signal != 0 {
+			g.signal = c.u8()
+		}
+		if g.gpsMask&gps7Operator != 0 {
+			g.operator = c.u32()
+		}
+	}
+
+	start := c.off
+	rec.TotalIO = g.count() + walkIO7(c, g.globalMask, nil)
+	if c.err == nil {
+		rec.io = c.b[start:c.off]
+	}
+}
+
+// walkIO7 recorre los grupos de IO presentes según la global mask y
+// devuelve cuántos IO leyó. Con fn == nil sólo avanza el cursor.
+func walkIO7(c *cursor, globalMask uint8, fn IOVisitor) uint16 {
+	var n uint16
+	for _, g := range [3]struct {
+		bit  uint8
+		size int
+	}{{mask7IO1, 1}, {mask7IO2, 2}, {mask7IO4, 4}} {
+		if globalMask&g.bit == 0 {
+			continue
+		}
+		cnt := int(c.u8())
+		for i := 0; i < cnt && c.err == nil; i++ {
+			id := uint16(c.u8())
+			var v uint64
+			switch g.size {
+			case 1:
+				v = uint64(c.u8())
+			case 2:
+				v = uint64(c.u16())
+			case 4:
+				v = uint64(c.u32())
+			}
+			if c.err != nil {
+				return n
+			}
+			n++
+			if fn != nil && !fn(id, g.size, v, nil) {
+				return n
+			}
+		}
+	}
+	return n
+}
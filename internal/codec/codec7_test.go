@@ -0,0 +1,129 @@
+package codec
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// codec7Data arma un AVL data Codec 7 con dos records:
+//
+//  1. prioridad 1, global mask 0x0F (GPS + IO 1B/2B/4B), máscara GPS 0xFF
+//  2. prioridad 0, global mask 0x03 (sólo lat/lon + grupo 1B vacío)
+func codec7Data() []byte {
+	b := []byte{CodecID7, 2}
+
+	// Record 1
+	b = binary.BigEndian.AppendUint32(b, 1<<30|500000000)
+	b = append(b, 0x0F, 0xFF)
+	// lat, lon (float32)
+	b = binary.BigEndian.AppendUint32(b, math.Float32bits(54.687157))
+	b = binary.BigEndian.AppendUint32(b, math.Float32bits(25.279652))
+	b = append(b, 0x00, 0x82)             // altitud 130 m
+	b = append(b, 0x40)                   // ángulo 64*360/256 = 90°
+	b = append(b, 0x3C)                   // 60 km/h
+	b = append(b, 0x09)                   // satélites
+	b = append(b, 0x0F, 0xA1, 0x2B, 0x67) // LAC 4001, Cell ID 11111
+	b = append(b, 0x04)                   // señal GSM
+	b = binary.BigEndian.AppendUint32(b, 24602)
+	b = append(b, 2, 1, 1, 69, 1)                // IO 1B: 1=1, 69=1
+	b = append(b, 1, 66, 0x30, 0xA2)             // IO 2B: 66=12450
+	b = append(b, 1, 16, 0x00, 0x01, 0x86, 0xA0) // IO 4B: 16=100000
+
+	// Record 2
+	b = binary.BigEndian.AppendUint32(b, 500000060)
+	b = append(b, 0x03, 0x01)
+	b = binary.BigEndian.AppendUint32(b, math.Float32bits(-34.603722))
+	b = binary.BigEndian.AppendUint32(b, math.Float32bits(-58.381592))
+	b = append(b, 0) // grupo 1B sin IO
+
+	return append(b, 2)
+}
+
+func TestParseCodec7(t *testing.T) {
+	pkt, err := ParseAVL(wrapFrame(codec7Data()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkt.CodecID != CodecID7 || pkt.Qty1 != 2 || pkt.Qty2 != 2 || len(pkt.Records) != 2 {
+		t.Fatalf("packet = %+v", pkt)
+	}
+
+	r1 := pkt.Records[0]
+	if want := time.Unix(epoch7Sec+500000000, 0).UTC(); !r1.Timestamp.Equal(want) || r1.Priority != 1 {
+		t.Fatalf("record 1 time/priority = %v/%d", r1.Timestamp, r1.Priority)
+	}
+	if math.Abs(r1.GPS.Latitude-54.687157) > 1e-5 || math.Abs(r1.GPS.Longitude-25.279652) > 1e-5 {
+		t.Fatalf("record 1 position = %v,%v", r1.GPS.Latitude, r1.GPS.Longitude)
+	}
+	if r1.GPS.Altitude != 130 || r1.GPS.Angle != 90 || r1.GPS.Speed != 60 || r1.GPS.Satellites != 9 {
+		t.Fatalf("record 1 gps = %+v", r1.GPS)
+	}
+	if r1.Generation != nil || r1.TotalIO != 8 {
+		t.Fatalf("record 1 generation/total = %v/%d", r1.Generation, r1.TotalIO)
+	}
+	want := map[uint16]IOItem{
+		io7AreaCode: {Size: 2, Val: 4001},
+		io7CellID:   {Size: 2, Val: 11111},
+		io7Signal:   {Size: 1, Val: 4},
+		io7Operator: {Size: 4, Val: 24602},
+		1:           {Size: 1, Val: 1},
+		69:          {Size: 1, Val: 1},
+		66:          {Size: 2, Val: 12450},
+		16:          {Size: 4, Val: 100000},
+	}
+	if !reflect.DeepEqual(r1.IO, want) {
+		t.Fatalf("record 1 IO = %v", r1.IO)
+	}
+
+	r2 := pkt.Records[1]
+	if want := time.Unix(epoch7Sec+500000060, 0).UTC(); !r2.Timestamp.Equal(want) || r2.Priority != 0 {
+		t.Fatalf("record 2 time/priority = %v/%d", r2.Timestamp, r2.Priority)
+	}
+	if math.Abs(r2.GPS.Latitude+34.603722) > 1e-5 || math.Abs(r2.GPS.Longitude+58.381592) > 1e-5 {
+		t.Fatalf("record 2 position = %v,%v", r2.GPS.Latitude, r2.GPS.Longitude)
+	}
+	if r2.TotalIO != 0 || len(r2.IO) != 0 || r2.GPS.Speed != 0 {
+		t.Fatalf("record 2 = %+v", r2)
+	}
+}
+
+func TestParseCodec7EachIOStops(t *testing.T) {
+	v := AcquirePacketView()
+	defer ReleasePacketView(v)
+	if err := DecodeAVLView(codec7Data(), v); err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint16
+	v.Records[0].EachIO(func(id uint16, size int, val uint64, raw []byte) bool {
+		ids = append(ids, id)
+		return id != io7Operator
+	})
+	if !reflect.DeepEqual(ids, []uint16{io7AreaCode, io7CellID, io7Signal, io7Operator}) {
+		t.Fatalf("visited %v", ids)
+	}
+}
+
+func TestParseCodec7Errors(t *testing.T) {
+	data := codec7Data()
+	for _, tc := range []struct {
+		name string
+		data []byte
+		kind DecodeErrorKind
+	}{
+		{"no records", []byte{CodecID7, 0, 0}, KindNoRecords},
+		{"cut in gps element", data[:12], KindTruncated},
+		{"cut in io group", data[:40], KindTruncated},
+		{"missing qty2", data[:len(data)-1], KindTruncated},
+		{"qty mismatch", append(append([]byte{}, data[:len(data)-1]...), 1), KindCountMismatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseAVLData(tc.data)
+			if ErrorKind(err) != tc.kind {
+				t.Fatalf("err = %v, want kind %v", err, tc.kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBuildCommandSpecFrames(t *testing.T) {
+	// Ejemplos de la wiki de Teltonika (Codec 12 "getinfo", Codec 14 "getver")
+	if got := BuildCodec12("getinfo"); !bytes.Equal(got, mustHex(t, "000000000000000F0C010500000007676574696E666F0100004312")) {
+		t.Errorf("codec12 = % X", got)
+	}
+	got, err := BuildCodec14("352093081452251", "getver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustHex(t, "00000000000000160E01050000000E0352093081452251676574766572010000D2C1"); !bytes.Equal(got, want) {
+		t.Errorf("codec14 = % X", got)
+	}
+	for _, imei := range []string{"", "35209308145225x", "35209308145225123"} {
+		if _, err := BuildCodec14(imei, "getver"); err == nil {
+			t.Errorf("BuildCodec14(%q) accepted", imei)
+		}
+	}
+}
+
+func TestParseCodec13(t *testing.T) {
+	// payload = 0D 01 05 | size | timestamp | "RFID:0A1B2C" | 01
+	body := cat(putU32(1700000000), []byte("RFID:0A1B2C"))
+	frame := wrapFrame(cat([]byte{CodecID13, 0x01, CmdTypeCommand}, putU32(uint32(len(body))), body, []byte{0x01}))
+
+	msg, err := ParseCodec13(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1700000000, 0).UTC(); !msg.Timestamp.Equal(want) || msg.Text != "RFID:0A1B2C" {
+		t.Fatalf("msg = %+v", msg)
+	}
+}
+
+func TestParseCodec14Response(t *testing.T) {
+	imei := mustHex(t, "0352093081452251")
+	ack := cat(imei, []byte("Ver:03.27.07"))
+
+	for _, tc := range []struct {
+		name  string
+		frame []byte
+		want  Codec14Response
+	}{
+		{
+			name:  "ack",
+			frame: wrapFrame(cat([]byte{CodecID14, 0x01, CmdTypeResponse}, putU32(uint32(len(ack))), ack, []byte{0x01})),
+			want:  Codec14Response{IMEI: "352093081452251", Text: "Ver:03.27.07", Ack: true},
+		},
+		{
+			// nACK de la wiki: el IMEI del comando no es el del equipo
+			name:  "nack imei mismatch",
+			frame: mustHex(t, "00000000000000100E011100000008035209308145225101000032AC"),
+			want:  Codec14Response{IMEI: "352093081452251", Ack: false},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := ParseCodec14Response(tc.frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != tc.want {
+				t.Fatalf("res = %+v, want %+v", res, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseCommandFrameErrors(t *testing.T) {
+	nack := mustHex(t, "00000000000000100E011100000008035209308145225101000032AC")
+	withByte := func(i int, b byte) []byte {
+		f := append([]byte{}, nack...)
+		f[i] = b
+		return f
+	}
+	getver, _ := BuildCodec14("352093081452251", "getver")
+
+	for _, tc := range []struct {
+		name  string
+		frame []byte
+		parse func([]byte) error
+	}{
+		{"too short", nack[:10], parse14},
+		{"incomplete", nack[:len(nack)-2], parse14},
+		{"wrong codec", nack, parse13},
+		{"bad resp size", withByte(14, 0x09), parse14},
+		{"bad qty2", withByte(23, 0x02), parse14},
+		{"bad crc", withByte(len(nack)-1, 0xAD), parse14},
+		{"command instead of reply", getver, parse14},
+		{"codec14 missing imei", wrapFrame(cat([]byte{CodecID14, 0x01, CmdTypeNack}, putU32(4), []byte{0, 0, 0, 0, 0x01})), parse14},
+		{"codec13 wrong type", wrapFrame(cat([]byte{CodecID13, 0x01, CmdTypeResponse}, putU32(4), []byte{0, 0, 0, 0, 0x01})), parse13},
+		{"codec13 missing timestamp", wrapFrame(cat([]byte{CodecID13, 0x01, CmdTypeCommand}, putU32(2), []byte{0, 0, 0x01})), parse13},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.parse(tc.frame); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func parse13(f []byte) error { _, err := ParseCodec13(f); return err }
+func parse14(f []byte) error { _, err := ParseCodec14Response(f); return err }
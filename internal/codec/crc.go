@@ -0,0 +1,55 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrCRCMismatch se devuelve cuando el CRC del frame no coincide con el calculado.
+var ErrCRCMismatch = errors.New("crc mismatch")
+
+// crc16Table: CRC-16/IBM por byte, precalculada para no iterar bit a bit.
+var crc16Table = func() (t [256]uint16) {
+	for i := range t {
+		crc := uint16(i)
+		for j := 0; j < 8; j++ {
+			if (crc & 1) == 1 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+		t[i] = crc
+	}
+	return t
+}()
+
+// crc16IBM calcula CRC-16/IBM (poly 0xA001 reflejado, init 0x0000),
+// el que usa Teltonika en todos sus frames TCP.
+func crc16IBM(b []byte) uint16 {
+	var crc uint16
+	for _, v := range b {
+		crc = (crc >> 8) ^ crc16Table[byte(crc)^v]
+	}
+	return crc
+}
+
+// VerifyFrameCRC valida el CRC de un frame TCP completo
+// (00000000 | dataLen(4B) | data | crc(4B)). El CRC se calcula sobre
+// el campo data (desde Codec ID hasta Qty2) y viaja como 00 00 hi lo.
+func VerifyFrameCRC(frame []byte) error {
+	if len(frame) < 12 {
+		return decodeErr(KindBadLength, 0, len(frame), -1, "frame too short")
+	}
+	dataLen := int(binary.BigEndian.Uint32(frame[4:8]))
+	end := 8 + dataLen
+	if end+4 > len(frame) {
+		return decodeErr(KindBadLength, frame[8], 4, -1, "declared len exceeds buffer")
+	}
+	got := binary.BigEndian.Uint32(frame[end : end+4])
+	calc := uint32(crc16IBM(frame[8:end]))
+	if got != calc {
+		return decodeErr(KindCRC, frame[8], end, -1, "%w: got 0x%04X calc 0x%04X", ErrCRCMismatch, got, calc)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// EncodeAVL serializa un AvlPacket como frame TCP completo
+// (00000000 | dataLen | AVL data | CRC16) en el codec indicado por pkt.CodecID
+// (Codec 8, 8E o 16). Qty1/Qty2, TotalIO, longitudes y CRC se calculan aquí;
+// los valores que traiga el paquete en esos campos se ignoran.
+func EncodeAVL(pkt AvlPacket) ([]byte, error) {
+	data, err := EncodeAVLData(pkt)
+	if err != nil {
+		return nil, err
+	}
+	return wrapFrame(data), nil
+}
+
+// EncodeAVLData serializa sólo el campo AVL data (desde Codec ID hasta Qty2),
+// el mismo que decodifica ParseAVLData.
+func EncodeAVLData(pkt AvlPacket) ([]byte, error) {
+	codec := pkt.CodecID
+	if !IsAVLCodec(codec) {
+		return nil, fmt.Errorf("codec 0x%X not an AVL codec", codec)
+	}
+	if codec == CodecID7 {
+		return nil, fmt.Errorf("codec 7 encoding not supported")
+	}
+	n := len(pkt.Records)
+	if n == 0 || n > 255 {
+		return nil, fmt.Errorf("record count %d out of range 1..255", n)
+	}
+	extended := codec == CodecID8E
+	wideIDs := codec != CodecID8
+
+	var out []byte
+	writeU8 := func(v uint8) { out = append(out, v) }
+	writeU16 := func(v uint16) { out = binary.BigEndian.AppendUint16(out, v) }
+	writeU32 := func(v uint32) { out = binary.BigEndian.AppendUint32(out, v) }
+	writeU64 := func(v uint64) { out = binary.BigEndian.AppendUint64(out, v) }
+
+	// Mismos anchos que ParseAVLData
+	writeIOID := func(id uint16) error {
+		if wideIDs {
+			writeU16(id)
+			return nil
+		}
+		if id > 0xFF {
+			return fmt.Errorf("io id %d does not fit codec 0x%02X", id, codec)
+		}
+		writeU8(uint8(id))
+		return nil
+	}
+	writeIOCount := func(c int) error {
+		if extended {
+			if c > 0xFFFF {
+				return fmt.Errorf("io count %d overflows", c)
+			}
+			writeU16(uint16(c))
+			return nil
+		}
+		if c > 0xFF {
+			return fmt.Errorf("io count %d does not fit codec 0x%02X", c, codec)
+		}
+		writeU8(uint8(c))
+		return nil
+	}
+
+	writeU8(codec)
+	writeU8(uint8(n))
+
+	for r, rec := range pkt.Records {
+		// Agrupar IO por tamaño, IDs ordenados para que la salida sea determinista
+		groups := map[int][]uint16{}
+		var xids []uint16
+		for id, it := range rec.IO {
+			if it.Raw != nil {
+				if !extended {
+					return nil, fmt.Errorf("record %d: x-bytes io %d only allowed in codec 8E", r, id)
+				}
+				if len(it.Raw) > 0xFFFF {
+					return nil, fmt.Errorf("record %d: x-bytes io %d too long", r, id)
+				}
+				xids = append(xids, id)
+				continue
+			}
+			switch it.Size {
+			case 1, 2, 4, 8:
+			default:
+				return nil, fmt.Errorf("record %d: io %d has invalid size %d", r, id, it.Size)
+			}
+			if it.Size < 8 && it.Val>>(8*uint(it.Size)) != 0 {
+				return nil, fmt.Errorf("record %d: io %d value %d overflows %dB", r, id, it.Val, it.Size)
+			}
+			groups[it.Size] = append(groups[it.Size], id)
+		}
+		for _, ids := range groups {
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		}
+		sort.Slice(xids, func(i, j int) bool { return xids[i] < xids[j] })
+
+		// Timestamp, prioridad y GPS
+		writeU64(uint64(rec.Timestamp.UnixMilli()))
+		writeU8(uint8(rec.Priority))
+		writeU32(uint32(int32(math.Round(rec.GPS.Longitude * 1e7))))
+		writeU32(uint32(int32(math.Round(rec.GPS.Latitude * 1e7))))
+		writeU16(uint16(rec.GPS.Altitude))
+		writeU16(uint16(rec.GPS.Angle))
+		writeU8(uint8(rec.GPS.Satellites))
+		writeU16(uint16(rec.GPS.Speed))
+
+		// IO header: event_io_id, [generation type], total_io
+		if err := writeIOID(uint16(rec.EventIOID)); err != nil {
+			return nil, fmt.Errorf("record %d: event %w", r, err)
+		}
+		if codec == CodecID16 {
+			var gen GenerationType
+			if rec.Generation != nil {
+				gen = *rec.Generation
+			}
+			writeU8(uint8(gen))
+		}
+		if err := writeIOCount(len(rec.IO)); err != nil {
+			return nil, fmt.Errorf("record %d: %w", r, err)
+		}
+
+		// Grupos de IO de tamaño fijo
+		for _, size := range []int{1, 2, 4, 8} {
+			ids := groups[size]
+			if err := writeIOCount(len(ids)); err != nil {
+				return nil, fmt.Errorf("record %d: %w", r, err)
+			}
+			for _, id := range ids {
+				if err := writeIOID(id); err != nil {
+					return nil, fmt.Errorf("record %d: %w", r, err)
+				}
+				v := rec.IO[id].Val
+				switch size {
+				case 1:
+					writeU8(uint8(v))
+				case 2:
+					writeU16(uint16(v))
+				case 4:
+					writeU32(uint32(v))
+				case 8:
+					writeU64(v)
+				}
+			}
+		}
+
+		// X-bytes (sólo 8E)
+		if extended {
+			writeU16(uint16(len(xids)))
+			for _, id := range xids {
+				raw := rec.IO[id].Raw
+				writeU16(id)
+				writeU16(uint16(len(raw)))
+				out = append(out, raw...)
+			}
+		}
+	}
+
+	writeU8(uint8(n)) // Qty2
+	return out, nil
+}
@@ -0,0 +1,220 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Frames de ejemplo de la documentación de Teltonika (Codec 8, 8E y 16).
+const (
+	specCodec8  = "000000000000003608010000016B40D8EA30010000000000000000000000000000000105021503010101425E0F01F10000601A014E0000000000000000010000C7CF"
+	specCodec8E = "000000000000004A8E010000016B412CEE000100000000000000000000000000000000010005000100010100010011001D00010010015E2C880002000B000000003544C87A000E000000001DD7E06A00000100002994"
+	specCodec16 = "000000000000005F10020000016BDBC7833000000000000000000000000000000000000B05040200010000030002000B00270042563A00000000016BDBC7871800000000000000000000000000000000000B05040200010000030002000B00260042563A00000200005FB3"
+)
+
+func mustHex(t testing.TB, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// testRecord arma un record con los IO dados; los valores de GPS son
+// exactos en grados*1e7 para que el round-trip compare con DeepEqual.
+func testRecord(codec uint8, io map[uint16]IOItem) AVLRecord {
+	rec := AVLRecord{
+		Timestamp: time.UnixMilli(1700000000123).UTC(),
+		Priority:  1,
+		GPS: GPSData{
+			Longitude:  -89.592586,
+			Latitude:   20.96737,
+			Altitude:   12,
+			Angle:      270,
+			Satellites: 9,
+			Speed:      42,
+		},
+		EventIOID: 0,
+		TotalIO:   len(io),
+		IO:        io,
+	}
+	if codec == CodecID16 {
+		gen := GenPeriodical
+		rec.Generation = &gen
+	}
+	return rec
+}
+
+func TestEncodeAVLRoundTrip(t *testing.T) {
+	groups := []struct {
+		name string
+		io   map[uint16]IOItem
+	}{
+		{"1B", map[uint16]IOItem{1: {Size: 1, Val: 1}, 21: {Size: 1, Val: 4}, 239: {Size: 1, Val: 0}}},
+		{"2B", map[uint16]IOItem{66: {Size: 2, Val: 12450}, 67: {Size: 2, Val: 0xFFFF}}},
+		{"4B", map[uint16]IOItem{16: {Size: 4, Val: 100000}, 199: {Size: 4, Val: 0xFFFFFFFF}}},
+		{"8B", map[uint16]IOItem{11: {Size: 8, Val: 0x3839353230313030}, 14: {Size: 8, Val: 1<<64 - 1}}},
+		{"X", map[uint16]IOItem{387: {Size: 3, Raw: []byte("+20")}, 385: {Size: 0, Raw: []byte{}}}},
+		{"mixed", map[uint16]IOItem{
+			1:   {Size: 1, Val: 1},
+			66:  {Size: 2, Val: 12450},
+			16:  {Size: 4, Val: 100000},
+			11:  {Size: 8, Val: 42},
+			264: {Size: 5, Raw: []byte("ABC12")},
+		}},
+	}
+	codecs := []struct {
+		name string
+		id   uint8
+	}{
+		{"codec8", CodecID8},
+		{"codec8E", CodecID8E},
+		{"codec16", CodecID16},
+	}
+
+	for _, c := range codecs {
+		for _, g := range groups {
+			t.Run(c.name+"/"+g.name, func(t *testing.T) {
+				hasX, wideID := false, false
+				for id, it := range g.io {
+					hasX = hasX || it.Raw != nil
+					wideID = wideID || id > 0xFF
+				}
+				pkt := AvlPacket{CodecID: c.id, Records: []AVLRecord{
+					testRecord(c.id, g.io),
+					testRecord(c.id, g.io),
+				}}
+
+				frame, err := EncodeAVL(pkt)
+				if (hasX && c.id != CodecID8E) || (wideID && c.id == CodecID8) {
+					if err == nil {
+						t.Fatalf("expected error encoding %s with %s IO", c.name, g.name)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("encode: %v", err)
+				}
+
+				// Longitud declarada y CRC
+				if got := binary.BigEndian.Uint32(frame[0:4]); got != 0 {
+					t.Fatalf("preamble = %08X", got)
+				}
+				dataLen := int(binary.BigEndian.Uint32(frame[4:8]))
+				if dataLen != len(frame)-12 {
+					t.Fatalf("data len = %d, frame carries %d", dataLen, len(frame)-12)
+				}
+				crc := binary.BigEndian.Uint32(frame[len(frame)-4:])
+				if crc>>16 != 0 || uint16(crc) != crc16IBM(frame[8:8+dataLen]) {
+					t.Fatalf("crc field %08X does not match data", crc)
+				}
+				if frame[8] != c.id || frame[9] != 2 || frame[len(frame)-5] != 2 {
+					t.Fatalf("codec/qty header mismatch: % X", frame[8:10])
+				}
+
+				got, err := ParseAVL(frame)
+				if err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if got.Qty1 != 2 || got.Qty2 != 2 || got.Len != uint32(dataLen) {
+					t.Fatalf("qty1=%d qty2=%d len=%d", got.Qty1, got.Qty2, got.Len)
+				}
+				if !reflect.DeepEqual(got.Records, pkt.Records) {
+					t.Fatalf("round-trip mismatch:\n want %+v\n got  %+v", pkt.Records, got.Records)
+				}
+
+				// El mismo AVL data viaja en UDP
+				data, err := EncodeAVLData(pkt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(data, frame[8:8+dataLen]) {
+					t.Fatal("EncodeAVLData differs from the frame payload")
+				}
+			})
+		}
+	}
+}
+
+func TestEncodeAVLSpecFrames(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		hex     string
+		codec   uint8
+		records int
+		crc     uint32
+	}{
+		{"codec8", specCodec8, CodecID8, 1, 0xC7CF},
+		{"codec8E", specCodec8E, CodecID8E, 1, 0x2994},
+		{"codec16", specCodec16, CodecID16, 2, 0x5FB3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			frame := mustHex(t, tc.hex)
+			pkt, err := ParseAVL(frame)
+			if err != nil {
+				t.Fatalf("decode spec frame: %v", err)
+			}
+			if pkt.CodecID != tc.codec || len(pkt.Records) != tc.records || pkt.CRC != tc.crc {
+				t.Fatalf("codec=%02X records=%d crc=%04X", pkt.CodecID, len(pkt.Records), pkt.CRC)
+			}
+
+			// El encoder ordena los IDs dentro de cada grupo, así que los
+			// bytes pueden diferir del ejemplo; el contenido no.
+			again, err := EncodeAVL(pkt)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if len(again) != len(frame) {
+				t.Fatalf("re-encoded len %d, spec %d", len(again), len(frame))
+			}
+			pkt2, err := ParseAVL(again)
+			if err != nil {
+				t.Fatalf("decode re-encoded: %v", err)
+			}
+			if !reflect.DeepEqual(pkt2.Records, pkt.Records) {
+				t.Fatalf("records differ after re-encoding")
+			}
+		})
+	}
+}
+
+func TestEncodeAVLRejects(t *testing.T) {
+	rec := testRecord(CodecID8E, map[uint16]IOItem{1: {Size: 1, Val: 1}})
+	for _, tc := range []struct {
+		name string
+		pkt  AvlPacket
+	}{
+		{"codec7", AvlPacket{CodecID: CodecID7, Records: []AVLRecord{rec}}},
+		{"codec12", AvlPacket{CodecID: CodecID12, Records: []AVLRecord{rec}}},
+		{"no records", AvlPacket{CodecID: CodecID8E}},
+		{"bad size", AvlPacket{CodecID: CodecID8E, Records: []AVLRecord{
+			testRecord(CodecID8E, map[uint16]IOItem{1: {Size: 3, Val: 1}}),
+		}}},
+		{"overflow", AvlPacket{CodecID: CodecID8, Records: []AVLRecord{
+			testRecord(CodecID8, map[uint16]IOItem{1: {Size: 1, Val: 256}}),
+		}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := EncodeAVL(tc.pkt); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestVerifyFrameCRC(t *testing.T) {
+	frame := mustHex(t, specCodec8E)
+	if err := VerifyFrameCRC(frame); err != nil {
+		t.Fatalf("spec frame: %v", err)
+	}
+	frame[20] ^= 0x01
+	err := VerifyFrameCRC(frame)
+	if !errors.Is(err, ErrCRCMismatch) || ErrorKind(err) != KindCRC {
+		t.Fatalf("corrupted frame: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DecodeErrorKind clasifica un fallo de decodificación AVL.
+type DecodeErrorKind string
+
+const (
+	KindTruncated     DecodeErrorKind = "truncated"      // lectura fuera del buffer
+	KindBadLength     DecodeErrorKind = "bad_length"     // frame corto o longitud declarada inválida
+	KindCRC           DecodeErrorKind = "crc"            // CRC-16/IBM no coincide
+	KindUnknownCodec  DecodeErrorKind = "unknown_codec"  // Codec ID no AVL
+	KindNoRecords     DecodeErrorKind = "no_records"     // Qty1 = 0
+	KindCountMismatch DecodeErrorKind = "count_mismatch" // Qty1 != Qty2
+	KindOther         DecodeErrorKind = "other"
+)
+
+// DecodeError es el error tipado de ParseAVL / ParseAVLData / DecodeAVLView.
+// Offset es la posición del byte problemático dentro del buffer recibido
+// por la función pública que falló (el frame completo en ParseAVL, el AVL
+// data en ParseAVLData). Record es el índice (0..) del record que se estaba
+// leyendo, o -1 si el fallo es de cabecera / pie.
+type DecodeError struct {
+	Kind   DecodeErrorKind
+	Codec  uint8
+	Offset int
+	Record int
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	s := fmt.Sprintf("avl %s at offset %d", e.Kind, e.Offset)
+	if e.Record >= 0 {
+		s += fmt.Sprintf(" (record %d)", e.Record)
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// ErrorKind devuelve la categoría de err, KindOther si no es un DecodeError.
+func ErrorKind(err error) DecodeErrorKind {
+	var de *DecodeError
+	if errors.As(err, &de) {
+		return de.Kind
+	}
+	return KindOther
+}
+
+func decodeErr(kind DecodeErrorKind, codec uint8, off, rec int, format string, args ...any) *DecodeError {
+	return &DecodeError{Kind: kind, Codec: codec, Offset: off, Record: rec, Err: fmt.Errorf(format, args...)}
+}
+
+// shiftOffset desplaza el offset de un DecodeError (p.ej. de AVL data a frame).
+func shiftOffset(err error, by int) error {
+	var de *DecodeError
+	if errors.As(err, &de) {
+		de.Offset += by
+	}
+	return err
+}
@@ -0,0 +1,186 @@
+package fmxxx
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// Beacon es un avistamiento BLE (iBeacon o Eddystone) reportado por el equipo.
+type Beacon struct {
+	Type string `json:"type"` // "ibeacon" | "eddystone"
+
+	// iBeacon
+	UUID  string `json:"uuid,omitempty"`
+	Major uint16 `json:"major,omitempty"`
+	Minor uint16 `json:"minor,omitempty"`
+
+	// Eddystone UID
+	Namespace string `json:"namespace,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+
+	RSSI      int      `json:"rssi"`
+	BatteryMV *int     `json:"battery_mv,omitempty"`
+	TempC     *float64 `json:"temp_c,omitempty"`
+}
+
+// Flags de cada beacon en IO 385.
+const (
+	beaconFlagRSSI    = 0x01
+	beaconFlagBattery = 0x02
+	beaconFlagTemp    = 0x04
+	beaconFlagIBeacon = 0x20 // 0 = Eddystone
+)
+
+// Parámetros TLV de cada beacon en IO 548.
+const (
+	advParamRSSI    = 0x00
+	advParamID      = 0x01
+	advParamBattery = 0x02
+	advParamTemp    = 0x03
+)
+
+const (
+	iBeaconIDLen   = 20 // UUID(16) + major(2) + minor(2)
+	eddystoneIDLen = 16 // namespace(10) + instance(6)
+)
+
+// DecodeBeaconList decodifica IO 385 (Beacon list):
+//
+//	data part(1B) | { flags(1B) | ID(20B iBeacon / 16B Eddystone) | RSSI(1B) | [batería mV(2B)] | [temp 0.01°C(2B)] }...
+func DecodeBeaconList(raw []byte) ([]Beacon, error) {
+	if len(raw) < 1 {
+		return nil, fmt.Errorf("beacon list: empty")
+	}
+	var out []Beacon
+	off := 1 // data part: nº de parte / total de partes
+	for off < len(raw) {
+		flags := raw[off]
+		off++
+
+		idLen := eddystoneIDLen
+		if flags&beaconFlagIBeacon != 0 {
+			idLen = iBeaconIDLen
+		}
+		if off+idLen > len(raw) {
+			return out, fmt.Errorf("beacon list: truncated id at %d", off)
+		}
+		b := beaconFromID(raw[off : off+idLen])
+		off += idLen
+
+		if flags&beaconFlagRSSI != 0 {
+			if off+1 > len(raw) {
+				return out, fmt.Errorf("beacon list: truncated rssi at %d", off)
+			}
+			b.RSSI = int(int8(raw[off]))
+			off++
+		}
+		if flags&beaconFlagBattery != 0 {
+			if off+2 > len(raw) {
+				return out, fmt.Errorf("beacon list: truncated battery at %d", off)
+			}
+			mv := int(binary.BigEndian.Uint16(raw[off:]))
+			b.BatteryMV = &mv
+			off += 2
+		}
+		if flags&beaconFlagTemp != 0 {
+			if off+2 > len(raw) {
+				return out, fmt.Errorf("beacon list: truncated temperature at %d", off)
+			}
+			t := float64(int16(binary.BigEndian.Uint16(raw[off:]))) / 100
+			b.TempC = &t
+			off += 2
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// DecodeAdvancedBeacons decodifica IO 548 (Advanced BLE beacon data):
+//
+//	header(1B) | { len(1B) | { paramID(1B) | paramLen(1B) | valor }... }...
+//
+// Parámetros desconocidos se ignoran.
+func DecodeAdvancedBeacons(raw []byte) ([]Beacon, error) {
+	if len(raw) < 1 {
+		return nil, fmt.Errorf("advanced beacons: empty")
+	}
+	var out []Beacon
+	off := 1 // header
+	for off < len(raw) {
+		recLen := int(raw[off])
+		off++
+		if off+recLen > len(raw) {
+			return out, fmt.Errorf("advanced beacons: truncated record at %d", off)
+		}
+		rec := raw[off : off+recLen]
+		off += recLen
+
+		var b Beacon
+		hasID := false
+		for p := 0; p < len(rec); {
+			if p+2 > len(rec) {
+				return out, fmt.Errorf("advanced beacons: truncated param header")
+			}
+			id, l := rec[p], int(rec[p+1])
+			p += 2
+			if p+l > len(rec) {
+				return out, fmt.Errorf("advanced beacons: truncated param 0x%02X", id)
+			}
+			v := rec[p : p+l]
+			p += l
+
+			switch {
+			case id == advParamRSSI && l == 1:
+				b.RSSI = int(int8(v[0]))
+			case id == advParamID && (l == iBeaconIDLen || l == eddystoneIDLen):
+				rssi := b.RSSI
+				b = beaconFromID(v)
+				b.RSSI = rssi
+				hasID = true
+			case id == advParamBattery && l == 2:
+				mv := int(binary.BigEndian.Uint16(v))
+				b.BatteryMV = &mv
+			case id == advParamTemp && l == 2:
+				t := float64(int16(binary.BigEndian.Uint16(v))) / 100
+				b.TempC = &t
+			}
+		}
+		if hasID {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+// DecodeBeacons despacha según el IO (385 o 548).
+func DecodeBeacons(id uint16, raw []byte) ([]Beacon, error) {
+	switch id {
+	case BLEBeacons:
+		return DecodeBeaconList(raw)
+	case AdvBLEBeacon:
+		return DecodeAdvancedBeacons(raw)
+	}
+	return nil, fmt.Errorf("io %d is not a beacon list", id)
+}
+
+func beaconFromID(id []byte) Beacon {
+	if len(id) == iBeaconIDLen {
+		return Beacon{
+			Type:  "ibeacon",
+			UUID:  formatUUID(id[:16]),
+			Major: binary.BigEndian.Uint16(id[16:18]),
+			Minor: binary.BigEndian.Uint16(id[18:20]),
+		}
+	}
+	return Beacon{
+		Type:      "eddystone",
+		Namespace: hex.EncodeToString(id[:10]),
+		Instance:  hex.EncodeToString(id[10:16]),
+	}
+}
+
+func formatUUID(b []byte) string {
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
@@ -0,0 +1,143 @@
+package fmxxx
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func hexBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeBeaconList(t *testing.T) {
+	// Ejemplo de la wiki de Teltonika (IO 385): parte 1/1, un iBeacon con
+	// RSSI, seguido de un Eddystone con RSSI, batería y temperatura.
+	raw := hexBytes(t, "11"+
+		"21 E2C56DB5DFFB48D2B060D0F5A71096E0 0000 0000 C5"+
+		"07 00112233445566778899 AABBCCDDEEFF BE 0BB8 FF38")
+
+	got, err := DecodeBeaconList(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d beacons", len(got))
+	}
+
+	ib := got[0]
+	if ib.Type != "ibeacon" || ib.UUID != "e2c56db5-dffb-48d2-b060-d0f5a71096e0" ||
+		ib.Major != 0 || ib.Minor != 0 || ib.RSSI != -59 || ib.BatteryMV != nil || ib.TempC != nil {
+		t.Fatalf("ibeacon = %+v", ib)
+	}
+
+	ed := got[1]
+	if ed.Type != "eddystone" || ed.Namespace != "00112233445566778899" || ed.Instance != "aabbccddeeff" || ed.RSSI != -66 {
+		t.Fatalf("eddystone = %+v", ed)
+	}
+	if ed.BatteryMV == nil || *ed.BatteryMV != 3000 {
+		t.Fatalf("battery = %v", ed.BatteryMV)
+	}
+	if ed.TempC == nil || *ed.TempC != -2 {
+		t.Fatalf("temperature = %v", ed.TempC)
+	}
+
+	// Sólo la parte de datos: lista vacía, no error
+	if got, err := DecodeBeaconList([]byte{0x11}); err != nil || len(got) != 0 {
+		t.Fatalf("empty list: %v %v", got, err)
+	}
+}
+
+func TestDecodeBeaconListTruncated(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		raw  string
+		kept int
+	}{
+		{"empty", "", 0},
+		{"short id", "11 21 E2C56DB5DFFB48D2", 0},
+		{"missing rssi", "11 21 E2C56DB5DFFB48D2B060D0F5A71096E0 0001 0002", 0},
+		{"second beacon cut", "11 21 E2C56DB5DFFB48D2B060D0F5A71096E0 0001 0002 C5 03 0011223344", 1},
+		{"missing temperature", "11 05 00112233445566778899AABBCCDDEEFF BE", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeBeaconList(hexBytes(t, tc.raw))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if len(got) != tc.kept {
+				t.Fatalf("kept %d beacons, want %d", len(got), tc.kept)
+			}
+		})
+	}
+}
+
+func TestDecodeAdvancedBeacons(t *testing.T) {
+	// IO 548: header | len | TLVs. El RSSI puede venir antes que el ID.
+	raw := hexBytes(t, "01"+
+		"1D 0001C5 0114E2C56DB5DFFB48D2B060D0F5A71096E0000A000B 02020C1C"+
+		"19 011000112233445566778899AABBCCDDEEFF 0001B0 0302FF9C"+
+		"05 0001A0 0900") // el último no trae ID: se descarta
+
+	got, err := DecodeAdvancedBeacons(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d beacons: %+v", len(got), got)
+	}
+
+	ib := got[0]
+	if ib.Type != "ibeacon" || ib.UUID != "e2c56db5-dffb-48d2-b060-d0f5a71096e0" ||
+		ib.Major != 10 || ib.Minor != 11 || ib.RSSI != -59 {
+		t.Fatalf("ibeacon = %+v", ib)
+	}
+	if ib.BatteryMV == nil || *ib.BatteryMV != 3100 || ib.TempC != nil {
+		t.Fatalf("ibeacon battery/temp = %v/%v", ib.BatteryMV, ib.TempC)
+	}
+
+	ed := got[1]
+	if ed.Type != "eddystone" || ed.Namespace != "00112233445566778899" || ed.Instance != "aabbccddeeff" || ed.RSSI != -80 {
+		t.Fatalf("eddystone = %+v", ed)
+	}
+	if ed.TempC == nil || *ed.TempC != -1 {
+		t.Fatalf("temperature = %v", ed.TempC)
+	}
+}
+
+func TestDecodeAdvancedBeaconsTruncated(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		raw  string
+	}{
+		{"empty", ""},
+		{"record longer than payload", "01 10 0001C5"},
+		{"param header cut", "01 04 0001C5 00"},
+		{"param value cut", "01 03 0114E2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := DecodeAdvancedBeacons(hexBytes(t, tc.raw)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestDecodeBeaconsDispatch(t *testing.T) {
+	list := hexBytes(t, "11 01 00112233445566778899AABBCCDDEEFF BE")
+	if bs, err := DecodeBeacons(BLEBeacons, list); err != nil || len(bs) != 1 {
+		t.Fatalf("385: %v %v", bs, err)
+	}
+	adv := hexBytes(t, "01 05 0001BE 0000")
+	if bs, err := DecodeBeacons(AdvBLEBeacon, adv); err != nil || len(bs) != 0 {
+		t.Fatalf("548 without id: %v %v", bs, err)
+	}
+	if _, err := DecodeBeacons(ISO6709Coord, list); err == nil {
+		t.Fatal("expected error for a non-beacon IO")
+	}
+}
@@ -0,0 +1,151 @@
+package fmxxx
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Element describe cómo interpretar un IO: nombre legible, unidad y escala.
+// valor = raw(con signo si Signed) * Multiplier + Offset
+type Element struct {
+	ID         uint16            `json:"-"`
+	Name       string            `json:"name"`
+	Unit       string            `json:"unit,omitempty"`
+	Multiplier float64           `json:"multiplier,omitempty"` // 0 = 1
+	Offset     float64           `json:"offset,omitempty"`
+	Signed     bool              `json:"signed,omitempty"`
+	Size       int               `json:"size,omitempty"` // bytes; para extender el signo si el record no lo indica
+	Enum       map[string]string `json:"enum,omitempty"` // raw -> etiqueta
+}
+
+// Value es un IO ya escalado.
+type Value struct {
+	Val   float64 `json:"val"`
+	Unit  string  `json:"unit,omitempty"`
+	Label string  `json:"label,omitempty"`
+}
+
+// Catalog: definiciones base por IO ID y overrides completos por modelo
+// (un override reemplaza la definición base de ese ID para ese modelo).
+type Catalog struct {
+	Elements map[uint16]Element
+	Models   map[string]map[uint16]Element
+}
+
+// catalogFile es el formato en disco (JSON): las claves son IDs en texto.
+//
+//	{
+//	  "elements": { "66": {"name":"ext_voltage","unit":"V","multiplier":0.001,"size":2} },
+//	  "models":   { "FMB003": { "30": {"name":"obd_dtc_count","size":1} } }
+//	}
+type catalogFile struct {
+	Elements map[string]Element            `json:"elements"`
+	Models   map[string]map[string]Element `json:"models"`
+}
+
+//go:embed catalog.json
+var defaultCatalogJSON []byte
+
+// DefaultCatalog devuelve el catálogo embebido en el binario.
+func DefaultCatalog() *Catalog {
+	c, err := ParseCatalog(defaultCatalogJSON)
+	if err != nil {
+		panic("fmxxx: embedded catalog: " + err.Error())
+	}
+	return c
+}
+
+// LoadCatalog lee un catálogo JSON desde disco.
+func LoadCatalog(path string) (*Catalog, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCatalog(b)
+}
+
+func ParseCatalog(b []byte) (*Catalog, error) {
+	var f catalogFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("catalog: %w", err)
+	}
+	c := &Catalog{
+		Elements: make(map[uint16]Element, len(f.Elements)),
+		Models:   make(map[string]map[uint16]Element, len(f.Models)),
+	}
+	if err := fillElements(c.Elements, f.Elements); err != nil {
+		return nil, err
+	}
+	for model, els := range f.Models {
+		m := make(map[uint16]Element, len(els))
+		if err := fillElements(m, els); err != nil {
+			return nil, fmt.Errorf("catalog model %s: %w", model, err)
+		}
+		c.Models[strings.ToUpper(model)] = m
+	}
+	return c, nil
+}
+
+func fillElements(dst map[uint16]Element, src map[string]Element) error {
+	for k, el := range src {
+		id, err := strconv.ParseUint(k, 10, 16)
+		if err != nil {
+			return fmt.Errorf("catalog: bad io id %q", k)
+		}
+		if el.Name == "" {
+			return fmt.Errorf("catalog: io %s without name", k)
+		}
+		el.ID = uint16(id)
+		dst[el.ID] = el
+	}
+	return nil
+}
+
+// Lookup devuelve la definición de un IO, con prioridad al override del modelo.
+func (c *Catalog) Lookup(model string, id uint16) (Element, bool) {
+	if c == nil {
+		return Element{}, false
+	}
+	if m, ok := c.Models[strings.ToUpper(model)]; ok {
+		if el, ok := m[id]; ok {
+			return el, true
+		}
+	}
+	el, ok := c.Elements[id]
+	return el, ok
+}
+
+// Scale aplica signo, multiplicador, offset y enum al valor crudo.
+// size es el tamaño con que llegó el IO (0 = usar el del catálogo).
+func (el Element) Scale(raw uint64, size int) Value {
+	if size <= 0 {
+		size = el.Size
+	}
+	v := float64(raw)
+	if el.Signed {
+		v = float64(signExtend(raw, size))
+	}
+
+	mul := el.Multiplier
+	if mul == 0 {
+		mul = 1
+	}
+	out := Value{
+		Val:  round(v*mul+el.Offset, 6),
+		Unit: el.Unit,
+	}
+	if el.Enum != nil {
+		out.Label = el.Enum[strconv.FormatUint(raw, 10)]
+	}
+	return out
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
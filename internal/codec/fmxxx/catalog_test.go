@@ -0,0 +1,171 @@
+package fmxxx
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// permIOSizes lee los perm_io_*.go y devuelve, por IO ID, los tamaños en
+// que aparece (perm_io_2n.go → 2; perm_io_nx.go → 0, largo variable).
+func permIOSizes(t *testing.T) map[uint16][]int {
+	t.Helper()
+	fileSizes := map[string]int{
+		"perm_io_1n.go": 1,
+		"perm_io_2n.go": 2,
+		"perm_io_4n.go": 4,
+		"perm_io_8n.go": 8,
+		"perm_io_nx.go": 0,
+	}
+	files, err := filepath.Glob("perm_io_*.go")
+	if err != nil || len(files) != len(fileSizes) {
+		t.Fatalf("perm_io files = %v, %v", files, err)
+	}
+	out := make(map[uint16][]int)
+	for _, name := range files {
+		size, ok := fileSizes[name]
+		if !ok {
+			t.Fatalf("%s: unexpected file name", name)
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			vs, ok := n.(*ast.ValueSpec)
+			if !ok {
+				return true
+			}
+			for _, v := range vs.Values {
+				if lit, ok := v.(*ast.BasicLit); ok && lit.Kind == token.INT {
+					id, _ := strconv.ParseUint(lit.Value, 10, 16)
+					out[uint16(id)] = append(out[uint16(id)], size)
+				}
+			}
+			return false
+		})
+	}
+	return out
+}
+
+func TestCatalogMatchesPermIO(t *testing.T) {
+	sizes := permIOSizes(t)
+	c := DefaultCatalog()
+
+	check := func(where string, el Element) {
+		known, ok := sizes[el.ID]
+		if !ok {
+			t.Errorf("%s io %d (%s): not in perm_io_*.go", where, el.ID, el.Name)
+			return
+		}
+		for _, s := range known {
+			if s == el.Size {
+				return
+			}
+		}
+		t.Errorf("%s io %d (%s): size %d, perm_io_*.go says %v", where, el.ID, el.Name, el.Size, known)
+	}
+	for _, el := range c.Elements {
+		check("base", el)
+	}
+	for model, els := range c.Models {
+		for _, el := range els {
+			check(model, el)
+		}
+	}
+}
+
+func TestElementScale(t *testing.T) {
+	c := DefaultCatalog()
+	get := func(id uint16) Element {
+		el, ok := c.Lookup("", id)
+		if !ok {
+			t.Fatalf("io %d not in catalog", id)
+		}
+		return el
+	}
+
+	for _, tc := range []struct {
+		name  string
+		id    uint16
+		raw   uint64
+		size  int
+		want  float64
+		unit  string
+		label string
+	}{
+		{"multiplier", ExtVolt, 12450, 2, 12.45, "V", ""},
+		{"multiplier 0.1", GnssHDOP, 7, 2, 0.7, "", ""},
+		{"no multiplier", VehicleSpeed, 87, 2, 87, "km/h", ""},
+		{"lls temp positive", LLS1Temp, 25, 1, 25, "°C", ""},
+		{"lls temp negative", LLS1Temp, 0xF6, 1, -10, "°C", ""},
+		{"lls temp catalog size", LLS2Temp, 0xF6, 0, -10, "°C", ""},
+		{"lls temp sent as 2B", LLS3Temp, 0xFFF6, 2, -10, "°C", ""},
+		{"signed 4B scaled", DallasTemp1, 0xFFFFFF38, 4, -20, "°C", ""},
+		{"lls fuel level error", LLS1FuelLvl, 0xFFFF, 2, -1, "kvants", ""},
+		{"enum", Ignition, 1, 1, 1, "", "on"},
+		{"enum multi", NetworkType, 99, 1, 99, "", "unknown"},
+		{"enum unknown value", Ignition, 7, 1, 7, "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := get(tc.id).Scale(tc.raw, tc.size)
+			if !near(v.Val, tc.want) || v.Unit != tc.unit || v.Label != tc.label {
+				t.Fatalf("Scale(%d, %d) = %+v, want %v %s %q", tc.raw, tc.size, v, tc.want, tc.unit, tc.label)
+			}
+		})
+	}
+}
+
+func TestCatalogModelOverride(t *testing.T) {
+	c, err := ParseCatalog([]byte(`{
+		"elements": {"30": {"name": "dtc_count", "size": 1}, "66": {"name": "ext_voltage", "unit": "V", "multiplier": 0.001, "size": 2}},
+		"models":   {"fmb003": {"30": {"name": "obd_dtc_count", "size": 1}}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		model string
+		id    uint16
+		want  string
+	}{
+		{"FMB003", DTCCount, "obd_dtc_count"},
+		{"fmb003", DTCCount, "obd_dtc_count"}, // sin distinguir mayúsculas
+		{"FMB003", ExtVolt, "ext_voltage"},    // sin override: el base
+		{"FMB920", DTCCount, "dtc_count"},
+		{"", DTCCount, "dtc_count"},
+	} {
+		el, ok := c.Lookup(tc.model, tc.id)
+		if !ok || el.Name != tc.want || el.ID != tc.id {
+			t.Errorf("Lookup(%q, %d) = %+v, %v; want %s", tc.model, tc.id, el, ok, tc.want)
+		}
+	}
+	if _, ok := c.Lookup("FMB003", 9999); ok {
+		t.Error("unknown io found")
+	}
+	if _, ok := (*Catalog)(nil).Lookup("FMB003", DTCCount); ok {
+		t.Error("nil catalog found an io")
+	}
+}
+
+func TestParseCatalogErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		json string
+	}{
+		{"bad json", `{"elements": [`},
+		{"bad io id", `{"elements": {"x1": {"name": "a"}}}`},
+		{"io id out of range", `{"elements": {"70000": {"name": "a"}}}`},
+		{"missing name", `{"elements": {"66": {"unit": "V"}}}`},
+		{"bad model io", `{"models": {"FMB003": {"30": {}}}}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseCatalog([]byte(tc.json)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package fmxxx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Decodificadores tipados de IO de longitud variable (X-bytes, Codec 8E).
+
+// nxStrings: IO X-bytes que son texto ASCII, con su nombre en el payload.
+var nxStrings = map[uint16]string{
+	BarcodeID:    "barcode_id",
+	DriverName:   "driver_name",
+	MSP500VendNa: "msp500_vendor_name",
+	MSP500VclNum: "msp500_vehicle_number",
+}
+
+// StringName devuelve el nombre del IO si es un X-bytes de texto conocido.
+func StringName(id uint16) (string, bool) {
+	name, ok := nxStrings[id]
+	return name, ok
+}
+
+// DecodeString limpia un X-bytes de texto: corta en el primer NUL,
+// descarta bytes no imprimibles y recorta espacios.
+func DecodeString(raw []byte) string {
+	var sb strings.Builder
+	for _, b := range raw {
+		if b == 0 {
+			break
+		}
+		if b >= 0x20 && b < 0x7F {
+			sb.WriteByte(b)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// ISO6709 es una posición ISO 6709 (IO 387) en grados decimales.
+type ISO6709 struct {
+	Lat float64  `json:"lat"`
+	Lon float64  `json:"lon"`
+	Alt *float64 `json:"alt,omitempty"`
+}
+
+// DecodeISO6709 parsea cadenas como "+40.20361-075.00417+12.4/",
+// "+4012.2166-07500.2502/" o "+401213.1-0750015.1+2.79CRSWGS_84/".
+// El número de dígitos enteros decide el formato: grados, grados+minutos
+// o grados+minutos+segundos.
+func DecodeISO6709(raw []byte) (ISO6709, error) {
+	var out ISO6709
+	s := DecodeString(raw)
+	if i := strings.Index(s, "CRS"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSuffix(s, "/")
+
+	// separar en componentes que empiezan por signo
+	var parts []string
+	for i := 0; i < len(s); {
+		if s[i] != '+' && s[i] != '-' {
+			return out, fmt.Errorf("iso6709: unexpected %q in %q", s[i], s)
+		}
+		j := i + 1
+		for j < len(s) && s[j] != '+' && s[j] != '-' {
+			j++
+		}
+		parts = append(parts, s[i:j])
+		i = j
+	}
+	if len(parts) < 2 {
+		return out, fmt.Errorf("iso6709: need lat and lon in %q", s)
+	}
+
+	lat, err := iso6709Angle(parts[0], 2)
+	if err != nil {
+		return out, err
+	}
+	lon, err := iso6709Angle(parts[1], 3)
+	if err != nil {
+		return out, err
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return out, fmt.Errorf("iso6709: out of range %q", s)
+	}
+	out.Lat, out.Lon = lat, lon
+
+	if len(parts) > 2 {
+		alt, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return out, fmt.Errorf("iso6709: bad altitude %q", parts[2])
+		}
+		out.Alt = &alt
+	}
+	return out, nil
+}
+
+// iso6709Angle convierte "±DD.D", "±DDMM.M" o "±DDMMSS.S" (degDigits=2 en
+// latitud, 3 en longitud) a grados decimales.
+func iso6709Angle(p string, degDigits int) (float64, error) {
+	sign := 1.0
+	if p[0] == '-' {
+		sign = -1
+	}
+	num := p[1:]
+	intPart := num
+	if i := strings.IndexByte(num, '.'); i >= 0 {
+		intPart = num[:i]
+	}
+
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("iso6709: bad component %q", p)
+	}
+
+	switch len(intPart) {
+	case degDigits: // grados
+		return sign * v, nil
+	case degDigits + 2: // grados + minutos
+		deg := float64(int(v / 100))
+		min := v - deg*100
+		return sign * (deg + min/60), nil
+	case degDigits + 4: // grados + minutos + segundos
+		deg := float64(int(v / 10000))
+		rest := v - deg*10000
+		min := float64(int(rest / 100))
+		sec := rest - min*100
+		return sign * (deg + min/60 + sec/3600), nil
+	}
+	return 0, fmt.Errorf("iso6709: bad component %q", p)
+}
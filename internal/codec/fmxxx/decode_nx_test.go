@@ -0,0 +1,105 @@
+package fmxxx
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestDecodeISO6709(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+
+	// Ejemplos del anexo H de ISO 6709:2008
+	for _, tc := range []struct {
+		in       string
+		lat, lon float64
+		alt      *float64
+	}{
+		{"+40-075/", 40, -75, nil},
+		{"+40.20361-075.00417/", 40.20361, -75.00417, nil},
+		{"+4012-07500/", 40.2, -75, nil},
+		{"+4012.22-07500.25/", 40 + 12.22/60, -(75 + 0.25/60), nil},
+		{"+401213-0750015/", 40 + 12.0/60 + 13.0/3600, -(75 + 15.0/3600), nil},
+		{"+401213.1-0750015.1/", 40 + 12.0/60 + 13.1/3600, -(75 + 15.1/3600), nil},
+		{"+401213.1-0750015.1+2.79CRSWGS_84/", 40 + 12.0/60 + 13.1/3600, -(75 + 15.1/3600), ptr(2.79)},
+		{"+27.5916+086.5640+8850CRSWGS_84/", 27.5916, 86.564, ptr(8850)},
+		{"+90+000/", 90, 0, nil},
+		{"-90+000/", -90, 0, nil},
+		{"+00-160.5/", 0, -160.5, nil},
+		{"-33.8688+151.2093-12.5", -33.8688, 151.2093, ptr(-12.5)},
+		{"+20.96737-089.59258/\x00\x00", 20.96737, -89.59258, nil},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := DecodeISO6709([]byte(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !near(got.Lat, tc.lat) || !near(got.Lon, tc.lon) {
+				t.Fatalf("got %v,%v want %v,%v", got.Lat, got.Lon, tc.lat, tc.lon)
+			}
+			switch {
+			case tc.alt == nil && got.Alt != nil:
+				t.Fatalf("unexpected altitude %v", *got.Alt)
+			case tc.alt != nil && (got.Alt == nil || !near(*got.Alt, *tc.alt)):
+				t.Fatalf("altitude = %v, want %v", got.Alt, *tc.alt)
+			}
+		})
+	}
+}
+
+func TestDecodeISO6709Errors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"/",
+		"+40.2/",           // falta longitud
+		"40.2-075.0/",      // sin signo
+		"+4-075/",          // latitud con 1 dígito
+		"+40-75/",          // longitud con 2 dígitos
+		"+95.0+010.0/",     // latitud fuera de rango
+		"+40.0+181.0/",     // longitud fuera de rango
+		"+40.0-075.0+x/",   // altitud inválida
+		"+40.0-075.0.1/",   // componente con dos puntos
+		"+40.0-075.0ABC+/", // basura antes del signo
+	} {
+		t.Run(in, func(t *testing.T) {
+			if got, err := DecodeISO6709([]byte(in)); err == nil {
+				t.Fatalf("expected error, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestDecodeString(t *testing.T) {
+	for _, tc := range []struct {
+		in   []byte
+		want string
+	}{
+		{[]byte("JUAN PEREZ"), "JUAN PEREZ"},
+		{[]byte("  AB123  "), "AB123"},
+		{[]byte("ABC\x00garbage"), "ABC"},
+		{[]byte("A\x01B\x7fC\xffD"), "ABCD"},
+		{[]byte{}, ""},
+		{[]byte{0, 'A'}, ""},
+	} {
+		if got := DecodeString(tc.in); got != tc.want {
+			t.Errorf("DecodeString(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+
+	for id, name := range map[uint16]string{
+		BarcodeID:    "barcode_id",
+		DriverName:   "driver_name",
+		MSP500VendNa: "msp500_vendor_name",
+		MSP500VclNum: "msp500_vehicle_number",
+	} {
+		if got, ok := StringName(id); !ok || got != name {
+			t.Errorf("StringName(%d) = %q, %v", id, got, ok)
+		}
+	}
+	if _, ok := StringName(ISO6709Coord); ok {
+		t.Error("ISO 6709 is not a plain string IO")
+	}
+}
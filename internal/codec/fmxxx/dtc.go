@@ -0,0 +1,85 @@
+package fmxxx
+
+import (
+	"sort"
+	"strings"
+)
+
+// DecodeDTC convierte el IO de códigos de falla (281, OBD/CAN) en códigos
+// estándar "P0107", "U0100", etc., ordenados y sin repetir. El equipo los
+// manda como texto ("P0107,P0108") o, según firmware, en binario SAE J2012
+// de 2 bytes por código:
+//
+//	bits 15-14: sistema (00 P, 01 C, 10 B, 11 U)
+//	bits 13-12: primer dígito (0-3)
+//	bits 11-0:  tres dígitos hex
+func DecodeDTC(raw []byte) []string {
+	var codes []string
+	if fields, ok := dtcText(raw); ok {
+		codes = fields
+	} else {
+		for i := 0; i+1 < len(raw); i += 2 {
+			v := uint16(raw[i])<<8 | uint16(raw[i+1])
+			if v == 0 {
+				continue // relleno
+			}
+			codes = append(codes, formatDTC(v))
+		}
+	}
+	return uniqueSorted(codes)
+}
+
+const dtcHex = "0123456789ABCDEF"
+
+func formatDTC(v uint16) string {
+	b := [5]byte{
+		"PCBU"[v>>14],
+		dtcHex[(v>>12)&0x3],
+		dtcHex[(v>>8)&0xF],
+		dtcHex[(v>>4)&0xF],
+		dtcHex[v&0xF],
+	}
+	return string(b[:])
+}
+
+func validDTC(c string) bool {
+	if len(c) != 5 || !strings.ContainsRune("PCBU", rune(c[0])) || c[1] < '0' || c[1] > '3' {
+		return false
+	}
+	for i := 2; i < 5; i++ {
+		if !strings.ContainsRune(dtcHex, rune(c[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// dtcText separa raw como texto y devuelve los códigos en mayúsculas; ok
+// sólo si hay al menos uno y TODOS los campos son códigos válidos. Un
+// binario SAE cuyo primer byte cae en 'P', 'C', 'B' o 'U' no pasa.
+func dtcText(raw []byte) (codes []string, ok bool) {
+	for _, f := range strings.FieldsFunc(string(raw), func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == 0
+	}) {
+		c := strings.ToUpper(f)
+		if !validDTC(c) {
+			return nil, false
+		}
+		codes = append(codes, c)
+	}
+	return codes, len(codes) > 0
+}
+
+func uniqueSorted(in []string) []string {
+	if len(in) == 0 {
+		return nil
+	}
+	sort.Strings(in)
+	out := in[:1]
+	for _, c := range in[1:] {
+		if c != out[len(out)-1] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package fmxxx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeDTC(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		raw  []byte
+		want []string
+	}{
+		{"text", []byte("P0107,P0108"), []string{"P0107", "P0108"}},
+		{"text mixed separators and case", []byte("u0100; p0300 P0107\x00\x00"), []string{"P0107", "P0300", "U0100"}},
+		{"text repeated", []byte("B1234,B1234"), []string{"B1234"}},
+		// SAE J2012: 0x0107 → P0107, 0x4123 → C0123, 0x8ABC → B0ABC, 0xC100 → U0100
+		{"binary", []byte{0x01, 0x07, 0x41, 0x23, 0x8A, 0xBC, 0xC1, 0x00}, []string{"B0ABC", "C0123", "P0107", "U0100"}},
+		{"binary padding", []byte{0x01, 0x07, 0x00, 0x00}, []string{"P0107"}},
+		{"binary odd trailing byte", []byte{0x30, 0x01, 0x99}, []string{"P3001"}},
+		// Binarios cuyo primer byte es ASCII 'P', 'U' o 'B': no son texto
+		{"binary starting with P", []byte{'P', '0', 0x00, 0x00}, []string{"C1030"}},
+		{"binary starting with U", []byte{'U', '1'}, []string{"C1531"}},
+		{"binary that looks alphanumeric", []byte("B010"), []string{"C0230", "P3130"}},
+		{"empty", nil, nil},
+		{"only padding", []byte{0, 0, 0, 0}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := DecodeDTC(tc.raw); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("DecodeDTC(% X) = %v, want %v", tc.raw, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDTCText(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		text bool
+	}{
+		{"P0107", true},
+		{"c0123,U3FFF", true},
+		{"P0107,X", false},
+		{"P4107", false}, // primer dígito fuera de 0-3
+		{"P010G", false},
+		{"P010", false},
+		{"P01077", false},
+		{", ;", false},
+		{"", false},
+	} {
+		if _, ok := dtcText([]byte(tc.raw)); ok != tc.text {
+			t.Errorf("dtcText(%q) = %v, want %v", tc.raw, ok, tc.text)
+		}
+	}
+}
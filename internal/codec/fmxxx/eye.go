@@ -0,0 +1,172 @@
+package fmxxx
+
+// Sensores BLE EYE (EYE Sensor / EYE Beacon). El equipo los reporta por
+// slot (1..4) en dos juegos de IO: los genéricos BLE (temperatura,
+// humedad, batería) y los específicos EYE (10800+).
+
+// IDs EYE: cada bloque ocupa 4 IDs consecutivos, uno por slot.
+const (
+	EyeTemp1       = 10800 // 2B signed, 0.01 °C
+	EyeHumidity1   = 10804 // 1B, %RH
+	EyeMagnet1     = 10808 // 1B, 0/1
+	EyeMovement1   = 10812 // 1B, 0/1
+	EyeMoveCount1  = 10816 // 2B
+	EyeLowBatt1    = 10820 // 1B, 0/1
+	EyeBattVolt1   = 10824 // 2B, mV
+	EyeRoll1       = 10828 // 2B signed, grados
+	EyePitch1      = 10832 // 1B signed, grados
+	eyeLastID      = EyePitch1 + 3
+	EyeSensorSlots = 4
+)
+
+// Valores centinela de temperatura BLE (crudos, sin escalar).
+const (
+	bleTempParseFail = 2000 // fallo al parsear los datos del sensor
+	bleTempNotFound  = 3000 // sensor no encontrado / desconectado
+	bleTempAbnormal  = 4000 // estado anormal del sensor
+)
+
+var (
+	bleTempIDs     = [EyeSensorSlots]uint16{BLETemp1, BLETemp2, BLETemp3, BLETemp4}
+	bleHumidityIDs = [EyeSensorSlots]uint16{BLEHumidity1, BLEHumidity2, BLEHumidity3, BLEHumidity4}
+	bleBattIDs     = [EyeSensorSlots]uint16{BLEBatt1, BLEBatt2, BLEBatt3, BLEBatt4}
+)
+
+// EyeSensor agrupa las lecturas de un slot. Los campos nil no vinieron en
+// el record o traían un valor inválido.
+type EyeSensor struct {
+	Slot          int      `json:"slot"`
+	Disconnected  bool     `json:"disconnected,omitempty"`
+	TempC         *float64 `json:"temp_c,omitempty"`
+	HumidityPct   *float64 `json:"humidity_pct,omitempty"`
+	Magnet        *bool    `json:"magnet,omitempty"`
+	Movement      *bool    `json:"movement,omitempty"`
+	MovementCount *int     `json:"movement_count,omitempty"`
+	LowBattery    *bool    `json:"low_battery,omitempty"`
+	BatteryPct    *int     `json:"battery_pct,omitempty"`
+	BatteryMV     *int     `json:"battery_mv,omitempty"`
+	RollDeg       *int     `json:"roll_deg,omitempty"`
+	PitchDeg      *int     `json:"pitch_deg,omitempty"`
+}
+
+// IsEyeIO indica si id es un IO de sensor BLE/EYE; esos IO se emiten
+// agrupados por slot y no como perm IO sueltos.
+func IsEyeIO(id uint16) bool {
+	if id >= EyeTemp1 && id <= eyeLastID {
+		return true
+	}
+	for i := 0; i < EyeSensorSlots; i++ {
+		if id == bleTempIDs[i] || id == bleHumidityIDs[i] || id == bleBattIDs[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// EyeLookup devuelve el valor crudo y el tamaño con que llegó el IO id.
+type EyeLookup func(id uint16) (val uint64, size int, ok bool)
+
+// DecodeEye arma las lecturas por slot. Sólo se devuelven los slots con
+// al menos un IO presente; un slot cuya temperatura trae un centinela se
+// marca Disconnected y se descarta esa temperatura. Si vienen el IO
+// genérico y el EYE del mismo dato, vale el EYE.
+func DecodeEye(get EyeLookup) []EyeSensor {
+	var out []EyeSensor
+	for i := 0; i < EyeSensorSlots; i++ {
+		s := EyeSensor{Slot: i + 1}
+		found := false
+		slot := uint16(i)
+
+		// Cada lectura pisa por completo a la anterior (temperatura y estado
+		// de conexión juntos): el ID EYE manda sobre el genérico si vienen
+		// ambos.
+		temp := func(id uint16) {
+			raw, size, ok := get(id)
+			if !ok {
+				return
+			}
+			found = true
+			s.TempC, s.Disconnected = nil, false
+			switch raw {
+			case bleTempNotFound, bleTempAbnormal, bleTempParseFail:
+				s.Disconnected = true
+				return
+			}
+			t := float64(signExtend(raw, size)) / 100
+			s.TempC = &t
+		}
+		temp(bleTempIDs[i])
+		temp(EyeTemp1 + slot)
+
+		if raw, _, ok := get(bleHumidityIDs[i]); ok {
+			found = true
+			if raw <= 1000 { // 0.1 %RH
+				h := float64(raw) / 10
+				s.HumidityPct = &h
+			}
+		}
+		if raw, _, ok := get(EyeHumidity1 + slot); ok {
+			found = true
+			s.HumidityPct = nil
+			if raw <= 100 {
+				h := float64(raw)
+				s.HumidityPct = &h
+			}
+		}
+		if raw, _, ok := get(bleBattIDs[i]); ok {
+			found = true
+			if raw <= 100 {
+				b := int(raw)
+				s.BatteryPct = &b
+			}
+		}
+		if raw, _, ok := get(EyeBattVolt1 + slot); ok {
+			found = true
+			mv := int(raw)
+			s.BatteryMV = &mv
+		}
+		if raw, _, ok := get(EyeMoveCount1 + slot); ok {
+			found = true
+			c := int(raw)
+			s.MovementCount = &c
+		}
+		if raw, size, ok := get(EyeRoll1 + slot); ok {
+			found = true
+			r := int(signExtend(raw, size))
+			s.RollDeg = &r
+		}
+		if raw, size, ok := get(EyePitch1 + slot); ok {
+			found = true
+			p := int(signExtend(raw, size))
+			s.PitchDeg = &p
+		}
+		for _, f := range []struct {
+			id  uint16
+			dst **bool
+		}{
+			{EyeMagnet1 + slot, &s.Magnet},
+			{EyeMovement1 + slot, &s.Movement},
+			{EyeLowBatt1 + slot, &s.LowBattery},
+		} {
+			if raw, _, ok := get(f.id); ok {
+				found = true
+				b := raw != 0
+				*f.dst = &b
+			}
+		}
+
+		if found {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// signExtend interpreta raw como entero con signo de size bytes.
+func signExtend(raw uint64, size int) int64 {
+	if size <= 0 || size >= 8 {
+		return int64(raw)
+	}
+	shift := uint(64 - 8*size)
+	return int64(raw<<shift) >> shift
+}
@@ -0,0 +1,90 @@
+package fmxxx
+
+import "testing"
+
+type eyeIO struct {
+	val  uint64
+	size int
+}
+
+func eyeGet(io map[uint16]eyeIO) EyeLookup {
+	return func(id uint16) (uint64, int, bool) {
+		it, ok := io[id]
+		return it.val, it.size, ok
+	}
+}
+
+func TestDecodeEyeTemperature(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		io     map[uint16]eyeIO
+		temp   *float64
+		discon bool
+	}{
+		{"generic only", map[uint16]eyeIO{BLETemp1: {2150, 2}}, ptrF(21.5), false},
+		{"eye only negative", map[uint16]eyeIO{EyeTemp1: {0xFF38, 2}}, ptrF(-2), false},
+		{"generic sentinel", map[uint16]eyeIO{BLETemp1: {bleTempNotFound, 2}}, nil, true},
+		{"eye overrides generic", map[uint16]eyeIO{BLETemp1: {2150, 2}, EyeTemp1: {2200, 2}}, ptrF(22), false},
+		{"valid eye clears generic sentinel", map[uint16]eyeIO{BLETemp1: {bleTempNotFound, 2}, EyeTemp1: {2200, 2}}, ptrF(22), false},
+		{"eye sentinel drops generic value", map[uint16]eyeIO{BLETemp1: {2150, 2}, EyeTemp1: {bleTempAbnormal, 2}}, nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := DecodeEye(eyeGet(tc.io))
+			if len(got) != 1 || got[0].Slot != 1 {
+				t.Fatalf("got %+v", got)
+			}
+			s := got[0]
+			if s.Disconnected != tc.discon {
+				t.Fatalf("disconnected = %v, want %v", s.Disconnected, tc.discon)
+			}
+			switch {
+			case tc.temp == nil && s.TempC != nil:
+				t.Fatalf("unexpected temperature %v", *s.TempC)
+			case tc.temp != nil && (s.TempC == nil || !near(*s.TempC, *tc.temp)):
+				t.Fatalf("temperature = %v, want %v", s.TempC, *tc.temp)
+			}
+		})
+	}
+}
+
+func TestDecodeEyeSlots(t *testing.T) {
+	got := DecodeEye(eyeGet(map[uint16]eyeIO{
+		BLEHumidity2:     {455, 2}, // 45.5 %RH
+		EyeHumidity1 + 1: {120, 1}, // fuera de rango: vale el EYE y se descarta
+		BLEBatt3:         {87, 1},
+		EyeBattVolt1 + 2: {3010, 2},
+		EyeMagnet1 + 2:   {1, 1},
+		EyeRoll1 + 3:     {0xFFA6, 2}, // -90
+		EyePitch1 + 3:    {0x1E, 1},
+	}))
+	if len(got) != 3 || got[0].Slot != 2 || got[1].Slot != 3 || got[2].Slot != 4 {
+		t.Fatalf("got %+v", got)
+	}
+	if got[0].HumidityPct != nil {
+		t.Fatalf("slot 2 humidity = %v", *got[0].HumidityPct)
+	}
+	s3 := got[1]
+	if s3.BatteryPct == nil || *s3.BatteryPct != 87 || s3.BatteryMV == nil || *s3.BatteryMV != 3010 ||
+		s3.Magnet == nil || !*s3.Magnet {
+		t.Fatalf("slot 3 = %+v", s3)
+	}
+	s4 := got[2]
+	if s4.RollDeg == nil || *s4.RollDeg != -90 || s4.PitchDeg == nil || *s4.PitchDeg != 30 {
+		t.Fatalf("slot 4 = %+v", s4)
+	}
+}
+
+func TestIsEyeIO(t *testing.T) {
+	for _, id := range []uint16{BLETemp1, BLETemp4, BLEHumidity3, BLEBatt2, EyeTemp1, eyeLastID} {
+		if !IsEyeIO(id) {
+			t.Errorf("IsEyeIO(%d) = false", id)
+		}
+	}
+	for _, id := range []uint16{24, 66, EyeTemp1 - 1, eyeLastID + 1} {
+		if IsEyeIO(id) {
+			t.Errorf("IsEyeIO(%d) = true", id)
+		}
+	}
+}
+
+func ptrF(v float64) *float64 { return &v }
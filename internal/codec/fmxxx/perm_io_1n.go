@@ -30,6 +30,11 @@ const (
 	DrvrcardExpDt  = 407
 	DriverStsEvt   = 409
 	BLEBatt1       = 29
+	BLEBatt2       = 20
+	BLEBatt3       = 22
+	BLEBatt4       = 23
 	MSP500Spdsen   = 502
 	WakeReason     = 637
+	DTCCount       = 30
+	CrashDetect    = 247
 )
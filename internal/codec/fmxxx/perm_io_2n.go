@@ -25,4 +25,10 @@ const (
 	AINSpeed     = 329
 	BLETemp1     = 25
 	BLEHumidity1 = 86
+	BLETemp2     = 26
+	BLETemp3     = 27
+	BLETemp4     = 28
+	BLEHumidity2 = 104
+	BLEHumidity3 = 106
+	BLEHumidity4 = 108
 )
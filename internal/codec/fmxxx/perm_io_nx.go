@@ -8,4 +8,7 @@ const (
 	UL20202SS    = 483
 	MSP500VendNa = 500
 	MSP500VclNum = 501
+	BLEBeacons   = 385
+	AdvBLEBeacon = 548
+	FaultCodes   = 281
 )
@@ -0,0 +1,237 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxFrameSize es el tamaño máximo de frame aceptado si no se indica otro.
+// Un paquete AVL real de FMB/FMC ronda 1-2 KB; 64 KB deja margen para X-bytes.
+const DefaultMaxFrameSize = 64 * 1024
+
+// KeepaliveByte es el byte suelto que algunos firmwares envían para mantener la sesión.
+const KeepaliveByte = 0xFF
+
+type FrameKind int
+
+const (
+	FrameIMEI      FrameKind = iota // handshake: len(2B) + IMEI ASCII
+	FrameAVL                        // Codec 7 / 8 / 8E / 16
+	FrameCommand                    // Codec 12 / 13 / 14 / 15
+	FrameKeepalive                  // 0xFF suelto
+)
+
+func (k FrameKind) String() string {
+	switch k {
+	case FrameIMEI:
+		return "imei"
+	case FrameAVL:
+		return "avl"
+	case FrameCommand:
+		return "command"
+	case FrameKeepalive:
+		return "keepalive"
+	}
+	return "unknown"
+}
+
+// Frame es una unidad completa leída del stream TCP.
+// Data es el frame tal cual llegó (preámbulo y CRC incluidos); IMEI sólo
+// se llena en FrameIMEI.
+type Frame struct {
+	Kind    FrameKind
+	CodecID uint8
+	IMEI    string
+	Data    []byte
+}
+
+// FrameReader separa el stream TCP de un equipo en frames. Nunca reserva
+// más de maxFrame bytes por frame: una cabecera con longitud absurda se
+// trata como basura y se resincroniza byte a byte hasta la siguiente
+// cabecera plausible (preámbulo 0, longitud acotada, Codec ID conocido y,
+// durante la resincronización, CRC válido).
+type FrameReader struct {
+	src      io.Reader
+	br       *bufio.Reader
+	maxFrame int
+
+	// OnResync se llama una vez por cada tramo de bytes descartados,
+	// con el motivo del primer descarte y la cantidad de bytes saltados.
+	OnResync func(reason string, skipped int)
+}
+
+func NewFrameReader(r io.Reader, maxFrame int) *FrameReader {
+	if maxFrame <= 0 {
+		maxFrame = DefaultMaxFrameSize
+	}
+	return &FrameReader{src: r, br: bufio.NewReaderSize(r, 4096), maxFrame: maxFrame}
+}
+
+// Next devuelve el siguiente frame completo. Los errores son los del
+// io.Reader subyacente (io.EOF al cerrar la conexión, timeouts, etc.).
+func (fr *FrameReader) Next() (Frame, error) {
+	skipped := 0
+	reason := ""
+	skip := func(why string) error {
+		if skipped == 0 {
+			reason = why
+		}
+		skipped++
+		_, err := fr.br.Discard(1)
+		return err
+	}
+	defer func() {
+		if skipped > 0 && fr.OnResync != nil {
+			fr.OnResync(reason, skipped)
+		}
+	}()
+
+	for {
+		b0, err := fr.br.Peek(1)
+		if err != nil {
+			return Frame{}, err
+		}
+
+		// ---- keepalive ----
+		// Durante la resincronización un 0xFF es un byte más de basura.
+		if b0[0] == KeepaliveByte && skipped == 0 {
+			fr.br.Discard(1)
+			return Frame{Kind: FrameKeepalive}, nil
+		}
+		if b0[0] != 0x00 {
+			if err := skip("bad_preamble"); err != nil {
+				return Frame{}, err
+			}
+			continue
+		}
+
+		hdr, err := fr.br.Peek(2)
+		if err != nil {
+			return Frame{}, err
+		}
+
+		// ---- handshake IMEI: 00 LL + dígitos ASCII ----
+		if hdr[1] != 0x00 {
+			imei, ok, err := fr.peekIMEI(int(hdr[1]))
+			if err != nil {
+				return Frame{}, err
+			}
+			if !ok {
+				if err := skip("bad_imei"); err != nil {
+					return Frame{}, err
+				}
+				continue
+			}
+			raw := make([]byte, 2+len(imei))
+			io.ReadFull(fr.br, raw)
+			return Frame{Kind: FrameIMEI, IMEI: imei, Data: raw}, nil
+		}
+
+		// ---- frame con preámbulo: 00000000 | dataLen(4B) | codec ... ----
+		hdr, err = fr.br.Peek(9)
+		if err != nil {
+			return Frame{}, err
+		}
+		if binary.BigEndian.Uint32(hdr[0:4]) != 0 {
+			if err := skip("bad_preamble"); err != nil {
+				return Frame{}, err
+			}
+			continue
+		}
+		dataLen := int(binary.BigEndian.Uint32(hdr[4:8]))
+		if dataLen < 3 || 12+dataLen > fr.maxFrame {
+			if err := skip("oversize"); err != nil {
+				return Frame{}, err
+			}
+			continue
+		}
+		codecID := hdr[8]
+		kind, ok := frameKindOf(codecID)
+		if !ok {
+			if err := skip("unknown_codec"); err != nil {
+				return Frame{}, err
+			}
+			continue
+		}
+
+		// Tras descartar bytes, un 00000000 con longitud y codec plausibles
+		// puede ser el medio de un payload: sólo se acepta si su CRC cuadra.
+		// En un stream alineado el CRC lo valida quien recibe el frame (y
+		// el frame corrupto se queda sin ACK).
+		if skipped > 0 {
+			if !fr.peekCRC(dataLen) {
+				if err := skip("bad_crc"); err != nil {
+					return Frame{}, err
+				}
+				continue
+			}
+		}
+
+		data := make([]byte, 12+dataLen)
+		if _, err := io.ReadFull(fr.br, data); err != nil {
+			return Frame{}, err
+		}
+		return Frame{Kind: kind, CodecID: codecID, Data: data}, nil
+	}
+}
+
+// peekCRC comprueba, sin consumir ni bloquear, el CRC-16/IBM del frame
+// candidato de dataLen bytes de datos. Sólo mira lo que ya está en el
+// buffer: si el frame no llegó entero se descarta como basura (un frame
+// real sin ACK lo retransmite el equipo). Si el frame no entra en el
+// buffer se agranda a maxFrame (una sola vez por conexión).
+func (fr *FrameReader) peekCRC(dataLen int) bool {
+	need := 12 + dataLen
+	if need > fr.br.Size() {
+		fr.grow()
+	}
+	if need > fr.br.Buffered() {
+		return false
+	}
+	b, _ := fr.br.Peek(need)
+	got := binary.BigEndian.Uint32(b[8+dataLen:])
+	return got == uint32(crc16IBM(b[8:8+dataLen]))
+}
+
+// grow pasa a un buffer de maxFrame bytes conservando lo ya leído.
+func (fr *FrameReader) grow() {
+	pending, _ := fr.br.Peek(fr.br.Buffered())
+	pending = append([]byte(nil), pending...)
+	fr.br = bufio.NewReaderSize(io.MultiReader(bytes.NewReader(pending), fr.src), fr.maxFrame)
+	// Carga lo pendiente sin tocar fr.src
+	fr.br.Peek(len(pending))
+}
+
+// peekIMEI comprueba, sin consumir, si hay un handshake IMEI válido de n dígitos.
+func (fr *FrameReader) peekIMEI(n int) (string, bool, error) {
+	if n < 8 || n > 20 {
+		return "", false, nil
+	}
+	b, err := fr.br.Peek(2 + n)
+	if err != nil {
+		return "", false, err
+	}
+	for _, c := range b[2:] {
+		if c < '0' || c > '9' {
+			return "", false, nil
+		}
+	}
+	return string(b[2:]), true, nil
+}
+
+func frameKindOf(codecID uint8) (FrameKind, bool) {
+	switch {
+	case IsAVLCodec(codecID):
+		return FrameAVL, true
+	case codecID == CodecID12, codecID == CodecID13, codecID == CodecID14, codecID == CodecID15:
+		return FrameCommand, true
+	}
+	return 0, false
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("%s codec=0x%02X len=%d", f.Kind, f.CodecID, len(f.Data))
+}
@@ -0,0 +1,233 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+type resync struct {
+	reason  string
+	skipped int
+}
+
+func newTestReader(stream []byte, maxFrame int) (*FrameReader, *[]resync) {
+	var got []resync
+	fr := NewFrameReader(bytes.NewReader(stream), maxFrame)
+	fr.OnResync = func(reason string, skipped int) {
+		got = append(got, resync{reason, skipped})
+	}
+	return fr, &got
+}
+
+func testFrame(t *testing.T, io map[uint16]IOItem) []byte {
+	t.Helper()
+	frame, err := EncodeAVL(AvlPacket{CodecID: CodecID8E, Records: []AVLRecord{testRecord(CodecID8E, io)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestFrameReaderKinds(t *testing.T) {
+	avl := mustHex(t, specCodec8)
+	cmd := BuildCodec12("getver")
+	hello := append([]byte{0x00, 0x0F}, "352093081452251"...)
+
+	fr, rs := newTestReader(cat(hello, avl, []byte{KeepaliveByte}, cmd), 0)
+	want := []struct {
+		kind  FrameKind
+		codec uint8
+		data  []byte
+	}{
+		{FrameIMEI, 0, hello},
+		{FrameAVL, CodecID8, avl},
+		{FrameKeepalive, 0, nil},
+		{FrameCommand, CodecID12, cmd},
+	}
+	for i, w := range want {
+		f, err := fr.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if f.Kind != w.kind || f.CodecID != w.codec || !bytes.Equal(f.Data, w.data) {
+			t.Fatalf("frame %d: got %s, want %s codec=0x%02X", i, f, w.kind, w.codec)
+		}
+		if f.Kind == FrameIMEI && f.IMEI != "352093081452251" {
+			t.Fatalf("imei = %q", f.IMEI)
+		}
+	}
+	if _, err := fr.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if len(*rs) != 0 {
+		t.Fatalf("unexpected resync on a clean stream: %v", *rs)
+	}
+}
+
+func TestFrameReaderResync(t *testing.T) {
+	valid := mustHex(t, specCodec8E)
+
+	// Cabecera plausible (preámbulo 0, largo chico, Codec 8E) con CRC que no
+	// cuadra: es lo que aparece al caer en el medio de un payload.
+	fake := cat([]byte{0, 0, 0, 0, 0, 0, 0, 0x10, CodecID8E}, bytes.Repeat([]byte{0x55}, 15), []byte{0, 0, 0x12, 0x34})
+
+	for _, tc := range []struct {
+		name    string
+		garbage []byte
+		reason  string
+	}{
+		{"bad preamble", []byte{0xAA, 0xBB, 0xCC}, "bad_preamble"},
+		{"fake header", cat([]byte{0x01}, fake), "bad_preamble"},
+		{"unknown codec", cat([]byte{0x01}, []byte{0, 0, 0, 0, 0, 0, 0, 0x10, 0x42}), "bad_preamble"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fr, rs := newTestReader(cat(tc.garbage, valid), 0)
+			f, err := fr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Kind != FrameAVL || !bytes.Equal(f.Data, valid) {
+				t.Fatalf("got %s, want the valid frame", f)
+			}
+			if len(*rs) != 1 || (*rs)[0].skipped != len(tc.garbage) || (*rs)[0].reason != tc.reason {
+				t.Fatalf("resync = %+v, want %d bytes (%s)", *rs, len(tc.garbage), tc.reason)
+			}
+		})
+	}
+}
+
+func TestFrameReaderResyncRejectsBadCRC(t *testing.T) {
+	valid := mustHex(t, specCodec8)
+
+	// Basura que termina justo en una cabecera creíble cuyo "frame" se
+	// come el comienzo del frame válido: sin CRC se aceptaría.
+	garbage := cat([]byte{0xEE}, []byte{0, 0, 0, 0, 0, 0, 0, 0x05, CodecID8, 0x01, 0x02})
+	fr, rs := newTestReader(cat(garbage, valid), 0)
+
+	f, err := fr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, valid) {
+		t.Fatalf("got %s, want the valid frame", f)
+	}
+	if len(*rs) != 1 || (*rs)[0].skipped != len(garbage) {
+		t.Fatalf("resync = %+v, want %d bytes", *rs, len(garbage))
+	}
+}
+
+func TestFrameReaderResyncLargeFrame(t *testing.T) {
+	// Frame válido más grande que el buffer inicial del reader (4 KB)
+	valid := testFrame(t, map[uint16]IOItem{
+		1:   {Size: 1, Val: 1},
+		264: {Size: 6000, Raw: bytes.Repeat([]byte{'A'}, 6000)},
+	})
+	// Tras la basura la primera copia no entra entera en el buffer y se
+	// descarta; la retransmisión del equipo sí se acepta.
+	fr, rs := newTestReader(cat([]byte{0x01, 0x02}, valid, valid), 0)
+
+	f, err := fr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, valid) {
+		t.Fatalf("got %s, want the %d-byte frame", f, len(valid))
+	}
+	if len(*rs) != 1 || (*rs)[0].skipped != 2+len(valid) {
+		t.Fatalf("resync = %+v", *rs)
+	}
+	if _, err := fr.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestFrameReaderResyncDoesNotBlock(t *testing.T) {
+	valid := mustHex(t, specCodec8)
+
+	// Basura con un 0xFF y una cabecera creíble que declara 1000 bytes:
+	// el equipo manda el frame válido y se queda esperando el ACK, así que
+	// esos 1000 bytes nunca llegan.
+	garbage := cat([]byte{0xEE, KeepaliveByte}, []byte{0, 0, 0, 0, 0, 0, 0x03, 0xE8, CodecID8, 0x01})
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write(cat(garbage, valid))
+
+	var rs []resync
+	fr := NewFrameReader(pr, 0)
+	fr.OnResync = func(reason string, skipped int) { rs = append(rs, resync{reason, skipped}) }
+
+	type result struct {
+		f   Frame
+		err error
+	}
+	res := make(chan result, 1)
+	go func() {
+		f, err := fr.Next()
+		res <- result{f, err}
+	}()
+	select {
+	case r := <-res:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if r.f.Kind != FrameAVL || !bytes.Equal(r.f.Data, valid) {
+			t.Fatalf("got %s, want the valid frame", r.f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next blocked on a fake header during resync")
+	}
+	if len(rs) != 1 || rs[0].skipped != len(garbage) || rs[0].reason != "bad_preamble" {
+		t.Fatalf("resync = %+v, want %d bytes", rs, len(garbage))
+	}
+}
+
+func TestFrameReaderOversize(t *testing.T) {
+	valid := mustHex(t, specCodec8)
+
+	t.Run("absurd length", func(t *testing.T) {
+		// dataLen = 0x7F000000: no debe reservarse
+		hdr := []byte{0, 0, 0, 0, 0x7F, 0, 0, 0, CodecID8E}
+		fr, rs := newTestReader(cat(hdr, valid), 0)
+		f, err := fr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(f.Data, valid) {
+			t.Fatalf("got %s", f)
+		}
+		if len(*rs) != 1 || (*rs)[0].reason != "oversize" || (*rs)[0].skipped != len(hdr) {
+			t.Fatalf("resync = %+v", *rs)
+		}
+	})
+
+	t.Run("above max frame", func(t *testing.T) {
+		big := testFrame(t, map[uint16]IOItem{264: {Size: 200, Raw: bytes.Repeat([]byte{'B'}, 200)}})
+		fr, rs := newTestReader(cat(big, valid), 128)
+		f, err := fr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(f.Data, valid) {
+			t.Fatalf("got %s, want the small frame", f)
+		}
+		if len(*rs) != 1 || (*rs)[0].reason != "oversize" || (*rs)[0].skipped != len(big) {
+			t.Fatalf("resync = %+v, want %d bytes oversize", *rs, len(big))
+		}
+	})
+}
+
+func TestFrameReaderTruncated(t *testing.T) {
+	valid := mustHex(t, specCodec8)
+	fr, _ := newTestReader(valid[:len(valid)-3], 0)
+	if _, err := fr.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// UDPPacket es la cabecera del canal UDP de Teltonika más el campo AVL data.
+//
+//	length(2B) | packetID(2B) | 0x01 | avlPacketID(1B) | imeiLen(2B) | IMEI | AVL data
+//
+// AVL data va desde el Codec ID hasta Qty2 (sin preámbulo ni CRC).
+type UDPPacket struct {
+	PacketID    uint16
+	AVLPacketID uint8
+	IMEI        string
+	Data        []byte
+}
+
+// ParseUDPPacket separa la cabecera UDP del AVL data.
+func ParseUDPPacket(b []byte) (UDPPacket, error) {
+	var p UDPPacket
+	if len(b) < 8 {
+		return p, fmt.Errorf("udp packet too short")
+	}
+	length := int(binary.BigEndian.Uint16(b[0:2]))
+	if length < 6 {
+		// packetID + 0x01 + avlPacketID + imeiLen como mínimo
+		return p, fmt.Errorf("udp declared len %d too short", length)
+	}
+	if 2+length > len(b) {
+		return p, fmt.Errorf("udp declared len %d exceeds datagram", length)
+	}
+	b = b[:2+length]
+
+	p.PacketID = binary.BigEndian.Uint16(b[2:4])
+	// b[4] = "not usable byte" (0x01)
+	p.AVLPacketID = b[5]
+
+	imeiLen := int(binary.BigEndian.Uint16(b[6:8]))
+	if imeiLen == 0 || imeiLen > 16 {
+		return p, fmt.Errorf("udp bad imei len %d", imeiLen)
+	}
+	if 8+imeiLen > len(b) {
+		return p, fmt.Errorf("udp imei len %d exceeds datagram", imeiLen)
+	}
+	imei := b[8 : 8+imeiLen]
+	for _, c := range imei {
+		if c < '0' || c > '9' {
+			return p, fmt.Errorf("udp imei not numeric")
+		}
+	}
+	p.IMEI = string(imei)
+	p.Data = b[8+imeiLen:]
+	return p, nil
+}
+
+// BuildUDPAck arma la respuesta al paquete UDP: length(2B)=0x0005 | packetID(2B) |
+// 0x01 | avlPacketID(1B) | registros aceptados(1B).
+func BuildUDPAck(packetID uint16, avlPacketID, accepted uint8) []byte {
+	out := make([]byte, 7)
+	binary.BigEndian.PutUint16(out[0:2], 5)
+	binary.BigEndian.PutUint16(out[2:4], packetID)
+	out[4] = 0x01
+	out[5] = avlPacketID
+	out[6] = accepted
+	return out
+}
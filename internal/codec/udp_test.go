@@ -0,0 +1,83 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// udpDatagram arma un datagrama UDP con el AVL data del ejemplo Codec 8.
+func udpDatagram(t *testing.T, packetID uint16, avlPacketID uint8, imei string) []byte {
+	t.Helper()
+	frame := mustHex(t, specCodec8)
+	data := frame[8 : len(frame)-4]
+
+	body := binary.BigEndian.AppendUint16(nil, packetID)
+	body = append(body, 0x01, avlPacketID)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(imei)))
+	body = append(append(body, imei...), data...)
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(body))), body...)
+}
+
+func TestParseUDPPacket(t *testing.T) {
+	frame := mustHex(t, specCodec8)
+	data := frame[8 : len(frame)-4]
+
+	dgram := udpDatagram(t, 0xCAFE, 0x05, "352093081452251")
+	// El equipo puede mandar bytes de relleno tras el largo declarado
+	padded := append(append([]byte{}, dgram...), 0, 0, 0)
+
+	for _, raw := range [][]byte{dgram, padded} {
+		p, err := ParseUDPPacket(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.PacketID != 0xCAFE || p.AVLPacketID != 0x05 || p.IMEI != "352093081452251" || !bytes.Equal(p.Data, data) {
+			t.Fatalf("packet = %+v", p)
+		}
+	}
+
+	// Retransmisión: mismo avlPacketID, distinto packetID; la deduplicación
+	// la hace el servidor, el parser sólo tiene que devolver los mismos campos
+	p, err := ParseUDPPacket(udpDatagram(t, 0xCAFF, 0x05, "352093081452251"))
+	if err != nil || p.PacketID != 0xCAFF || p.AVLPacketID != 0x05 {
+		t.Fatalf("retransmission = %+v, %v", p, err)
+	}
+}
+
+func TestParseUDPPacketErrors(t *testing.T) {
+	dgram := udpDatagram(t, 0xCAFE, 0x05, "352093081452251")
+	withLen := func(off, n int) []byte {
+		b := append([]byte{}, dgram...)
+		binary.BigEndian.PutUint16(b[off:], uint16(n))
+		return b
+	}
+
+	for _, tc := range []struct {
+		name string
+		raw  []byte
+	}{
+		{"empty", nil},
+		{"truncated header", dgram[:7]},
+		{"truncated body", dgram[:len(dgram)-10]},
+		{"declared len under header", mustHex(t, "0002000101050000")},
+		{"declared len zero", mustHex(t, "0000000101050000")},
+		{"declared len oversize", withLen(0, len(dgram))},
+		{"imei len oversize", withLen(6, len(dgram))},
+		{"imei len too long", withLen(6, 17)},
+		{"imei len zero", withLen(6, 0)},
+		{"imei not numeric", udpDatagram(t, 0xCAFE, 0x05, "35209308145225A")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseUDPPacket(tc.raw); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestBuildUDPAck(t *testing.T) {
+	if got := BuildUDPAck(0xCAFE, 0x05, 1); !bytes.Equal(got, mustHex(t, "0005CAFE010501")) {
+		t.Fatalf("ack = % X", got)
+	}
+}
@@ -2,23 +2,57 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
 	TCPPort           string
+	UDPPort           string
 	MetricsPort       string
+	AdminAddr         string
+	AdminToken        string
 	GRPCServer        string
 	RedisAddr         string
 	GetVerOnHandshake bool
+	MaxFrameSize      int
+	LogRawFrames      bool
+	IOCatalogPath     string
+	ProfilesPath      string
+	CameraPort        string
+	MediaDir          string
+	DupSessionPolicy  string
+	HandshakeTimeout  time.Duration
+	IdleTimeout       time.Duration
+	WriteTimeout      time.Duration
+	ShutdownTimeout   time.Duration
+	WorkerShards      int
+	WorkerQueue       int
 }
 
 func Load() Config {
 	return Config{
 		TCPPort:           getEnv("TCP_PORT", "8001"),
+		UDPPort:           getEnv("UDP_PORT", "8001"),
 		MetricsPort:       getEnv("METRICS_PORT", "9000"),
+		AdminAddr:         getEnv("ADMIN_ADDR", "127.0.0.1:9001"),
+		AdminToken:        getEnv("ADMIN_TOKEN", ""),
 		GRPCServer:        getEnv("GRPC_SERVER", "localhost:50051"),
 		RedisAddr:         getEnv("REDIS_ADDR", "localhost:6379"),
 		GetVerOnHandshake: getEnv("GETVER_ON_HANDSHAKE", "1") != "0",
+		MaxFrameSize:      getEnvInt("MAX_FRAME_SIZE", 64*1024),
+		LogRawFrames:      getEnv("LOG_RAW_FRAMES", "0") != "0",
+		IOCatalogPath:     getEnv("IO_CATALOG", ""),
+		ProfilesPath:      getEnv("DEVICE_PROFILES", ""),
+		CameraPort:        getEnv("CAMERA_PORT", ""),
+		MediaDir:          getEnv("MEDIA_DIR", "/var/lib/codec-svr/media"),
+		DupSessionPolicy:  getEnv("DUP_SESSION_POLICY", "close_old"),
+		HandshakeTimeout:  getEnvDuration("HANDSHAKE_TIMEOUT", 30*time.Second),
+		IdleTimeout:       getEnvDuration("IDLE_TIMEOUT", 10*time.Minute),
+		WriteTimeout:      getEnvDuration("WRITE_TIMEOUT", 10*time.Second),
+		ShutdownTimeout:   getEnvDuration("SHUTDOWN_TIMEOUT", 20*time.Second),
+		WorkerShards:      getEnvInt("WORKER_SHARDS", 16),
+		WorkerQueue:       getEnvInt("WORKER_QUEUE", 256),
 	}
 }
 
@@ -28,3 +62,22 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	if val := os.Getenv(key); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+// getEnvDuration acepta duraciones de Go ("30s", "10m").
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if val := os.Getenv(key); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
@@ -1,9 +1,12 @@
 package dispatcher
 
 import (
+	"codec-svr/internal/profile"
+	"codec-svr/internal/session"
 	"codec-svr/internal/store"
+	"context"
+	"fmt"
 	"log/slog"
-	"net"
 	"strings"
 	"sync"
 	"time"
@@ -75,21 +78,21 @@ func getState(imei, cmd string) *perCmdState {
                      REQUIRED DATA CHECK
 ======================================================================= */
 
-func needsToRun(imei, cmd string) bool {
+func needsToRun(ctx context.Context, imei, cmd string) bool {
 
 	switch cmd {
 
 	case "getver":
-		fw := store.GetStringSafe("dev:" + imei + ":fw")
-		model := store.GetStringSafe("dev:" + imei + ":model")
+		fw := store.GetStringSafe(ctx, "dev:"+imei+":fw")
+		model := store.GetStringSafe(ctx, "dev:"+imei+":model")
 		return fw == "" || model == ""
 
 	case "iccid_primary":
-		return store.GetStringSafe("dev:"+imei+":iccid") == ""
+		return store.GetStringSafe(ctx, "dev:"+imei+":iccid") == ""
 
 	case "iccid_fallback":
 		// solo si iccid sigue vacío
-		return store.GetStringSafe("dev:"+imei+":iccid") == ""
+		return store.GetStringSafe(ctx, "dev:"+imei+":iccid") == ""
 	}
 
 	return false
@@ -99,7 +102,9 @@ func needsToRun(imei, cmd string) bool {
                   UNIVERSAL COMMAND SCHEDULE FUNCTION
 ======================================================================= */
 
-func TrySchedule(imei, cmdName string, conn net.Conn, lg *slog.Logger) {
+// TrySchedule envía cmdName a la sesión viva del IMEI si el comando,
+// el perfil y los límites lo permiten.
+func TrySchedule(ctx context.Context, imei, cmdName string, lg *slog.Logger) {
 
 	cmd, ok := getCmd(cmdName)
 	if !ok {
@@ -107,13 +112,24 @@ func TrySchedule(imei, cmdName string, conn net.Conn, lg *slog.Logger) {
 		return
 	}
 
+	// Sólo a equipos conectados
+	sess, ok := session.Get(imei)
+	if !ok {
+		return
+	}
+
 	// Optional condition
 	if cmd.Condition != nil && !cmd.Condition(imei) {
 		return
 	}
 
+	// The device profile must allow it
+	if !profile.ForIMEI(ctx, imei).Allows(cmdName) {
+		return
+	}
+
 	// Should we run this command?
-	if !needsToRun(imei, cmdName) {
+	if !needsToRun(ctx, imei, cmdName) {
 		return
 	}
 
@@ -131,8 +147,14 @@ func TrySchedule(imei, cmdName string, conn net.Conn, lg *slog.Logger) {
 		return
 	}
 
+	/* ---------- no ad-hoc command in flight ---------- */
+	if sess.Busy() {
+		return
+	}
+
 	/* -------------- daily limit via Redis ------------ */
 	allowed, dailyCount, err := store.IncDailyCmdCounter(
+		ctx,
 		imei,
 		cmdName,
 		cmd.DailyLimit,
@@ -143,7 +165,7 @@ func TrySchedule(imei, cmdName string, conn net.Conn, lg *slog.Logger) {
 
 	/* --------------------- SEND --------------------- */
 	frame := cmd.Build()
-	if _, err := conn.Write(frame); err != nil {
+	if err := sess.SendAuto(frame); err != nil {
 		lg.Error("command send failed", "cmd", cmdName, "imei", imei, "err", err)
 		return
 	}
@@ -163,26 +185,32 @@ func TrySchedule(imei, cmdName string, conn net.Conn, lg *slog.Logger) {
               UNIVERSAL ROUTER FOR COMMAND RESPONSES
 ======================================================================= */
 
-func HandleCommandResponses(imei, text string) {
+func HandleCommandResponses(ctx context.Context, imei, text string) {
 
 	lower := strings.ToLower(text)
 
 	// GETVER
 	if strings.Contains(lower, "ver:") ||
 		strings.Contains(lower, "hw:") {
-		HandleGetVerResponse(imei, text)
+		HandleGetVerResponse(ctx, imei, text)
 		return
 	}
 
 	// ICCID PRIMARY
 	if strings.Contains(lower, "iccid") {
-		HandleICCIDResponse(imei, text)
+		HandleICCIDResponse(ctx, imei, text)
 		return
 	}
 
 	// ICCID FALLBACK
 	if strings.Contains(lower, "param values") {
-		HandleICCIDResponse(imei, text)
+		HandleICCIDResponse(ctx, imei, text)
 		return
 	}
 }
+
+// HandleCommandNack registra un nACK de Codec 14: el equipo recibió un
+// comando dirigido a otro IMEI (devIMEI es el IMEI que el equipo reporta).
+func HandleCommandNack(imei, devIMEI string) {
+	fmt.Printf("[CMD] codec14 nACK imei=%s device_imei=%s\n", imei, devIMEI)
+}
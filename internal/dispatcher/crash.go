@@ -0,0 +1,158 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"codec-svr/internal/codec"
+	"codec-svr/internal/codec/fmxxx"
+	"codec-svr/internal/observability"
+	"codec-svr/internal/pipeline"
+	"codec-svr/internal/store"
+)
+
+// Ante un choque el equipo manda una ráfaga de records con el IO 247 y el
+// acelerómetro (17/18/19) a alta frecuencia, repartida en varios paquetes.
+// Las muestras se acumulan por IMEI y el trazo se cierra cuando llega un
+// record normal, cuando la ráfaga se detiene (crashTraceIdle) o al llegar
+// a crashTraceMax muestras. Todos los cierres corren en el shard del IMEI
+// (el de cierre por inactividad se encola con Submit), en orden con sus
+// records.
+const crashTraceMax = 10000
+
+// crashTraceIdle es variable para acortarla en los tests.
+var crashTraceIdle = 10 * time.Second
+
+type crashBuffer struct {
+	trace   pipeline.CrashTrace
+	timer   *time.Timer
+	touched time.Time // última muestra (reloj local)
+}
+
+var (
+	crashMu      sync.Mutex
+	crashBuffers = make(map[string]*crashBuffer)
+
+	// crashOpen marca los IMEI con trazo abierto; se consulta sin crashMu
+	// en cada record normal.
+	crashOpen sync.Map // imei -> struct{}
+)
+
+// isCrashSample: record de trazo de choque (IO 247 activo + acelerómetro).
+func isCrashSample(io map[uint16]codec.IOItem) bool {
+	it, ok := io[fmxxx.CrashDetect]
+	if !ok || it.Raw != nil || it.Val == 0 {
+		return false
+	}
+	_, x := io[fmxxx.AxisX]
+	_, y := io[fmxxx.AxisY]
+	_, z := io[fmxxx.AxisZ]
+	return x || y || z
+}
+
+// addCrashSample suma el record al trazo en curso del IMEI. Devuelve true
+// si el trazo llegó a crashTraceMax y hay que cerrarlo.
+func addCrashSample(imei string, rec *codec.RecordView, io map[uint16]codec.IOItem) bool {
+	crashMu.Lock()
+	defer crashMu.Unlock()
+
+	ts := rec.Time()
+	gps := rec.GPS()
+	b := crashBuffers[imei]
+	if b == nil {
+		b = &crashBuffer{trace: pipeline.CrashTrace{
+			IMEI:  imei,
+			Kind:  int(io[fmxxx.CrashDetect].Val),
+			Start: ts.Format(time.RFC3339Nano),
+		}}
+		b.trace.ID = imei + "-" + strconv.FormatInt(rec.TimestampMs, 10)
+		b.timer = time.AfterFunc(crashTraceIdle, func() {
+			Submit(imei, func(ctx context.Context) { flushIdleCrashTrace(ctx, imei) })
+		})
+		crashBuffers[imei] = b
+		crashOpen.Store(imei, struct{}{})
+	} else {
+		b.timer.Reset(crashTraceIdle)
+	}
+	b.touched = time.Now()
+
+	b.trace.End = ts.Format(time.RFC3339Nano)
+	b.trace.Samples = append(b.trace.Samples, pipeline.CrashSample{
+		TimestampMs: rec.TimestampMs,
+		X:           axisMG(io, fmxxx.AxisX),
+		Y:           axisMG(io, fmxxx.AxisY),
+		Z:           axisMG(io, fmxxx.AxisZ),
+		Lat:         gps.Latitude,
+		Lon:         gps.Longitude,
+		Spd:         gps.Speed,
+	})
+
+	return len(b.trace.Samples) >= crashTraceMax
+}
+
+// flushIdleCrashTrace cierra el trazo si sigue sin muestras nuevas: entre
+// que venció el timer y que corrió en el shard pudo llegar otra muestra.
+func flushIdleCrashTrace(ctx context.Context, imei string) {
+	crashMu.Lock()
+	b := crashBuffers[imei]
+	idle := b != nil && time.Since(b.touched) >= crashTraceIdle
+	crashMu.Unlock()
+	if idle {
+		flushCrashTrace(ctx, imei, "idle")
+	}
+}
+
+// flushCrashTrace cierra el trazo en curso del IMEI (si hay), lo guarda
+// como artefacto y emite el evento de choque que lo referencia.
+func flushCrashTrace(ctx context.Context, imei, reason string) {
+	if _, open := crashOpen.Load(imei); !open {
+		return
+	}
+	crashMu.Lock()
+	b := crashBuffers[imei]
+	delete(crashBuffers, imei)
+	crashOpen.Delete(imei)
+	crashMu.Unlock()
+	if b == nil {
+		return
+	}
+	b.timer.Stop()
+	ct := &b.trace
+
+	data, err := json.Marshal(ct)
+	if err != nil {
+		fmt.Printf("[CRASH] marshal trace %s: %v\n", ct.ID, err)
+		return
+	}
+	if err := store.SaveCrashTrace(ctx, imei, ct.ID, data); err != nil {
+		fmt.Printf("[CRASH] store trace %s: %v\n", ct.ID, err)
+	}
+	fmt.Printf("[CRASH] trace closed imei=%s id=%s samples=%d reason=%s\n", imei, ct.ID, len(ct.Samples), reason)
+
+	lg := observability.NewLogger()
+	for _, m := range pipeline.CrashToGRPC(ct) {
+		lg.Info("gRPC payload", "imei", imei, "payload", m)
+	}
+}
+
+// axisMG: IO 17/18/19 llegan como int16 en mG.
+func axisMG(io map[uint16]codec.IOItem, id uint16) int {
+	return int(int16(io[id].Val))
+}
+
+// flushAllCrashTraces cierra todos los trazos abiertos (apagado).
+func flushAllCrashTraces(ctx context.Context, reason string) {
+	crashMu.Lock()
+	imeis := make([]string, 0, len(crashBuffers))
+	for imei := range crashBuffers {
+		imeis = append(imeis, imei)
+	}
+	crashMu.Unlock()
+	for _, imei := range imeis {
+		flushCrashTrace(ctx, imei, reason)
+	}
+}
@@ -0,0 +1,179 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"codec-svr/internal/codec"
+	"codec-svr/internal/codec/fmxxx"
+	"codec-svr/internal/pipeline"
+	"codec-svr/internal/store/storetest"
+)
+
+const crashStartMs = 1700000000000
+
+// crashPacket arma un paquete Codec 8E con n records desde startMs, cada
+// 10 ms: muestras de choque (IO 247 + acelerómetro) o records normales.
+func crashPacket(t *testing.T, startMs int64, n int, crash bool) *codec.PacketView {
+	t.Helper()
+	recs := make([]codec.AVLRecord, n)
+	for i := range recs {
+		io := map[uint16]codec.IOItem{fmxxx.Ignition: {Size: 1, Val: 1}}
+		if crash {
+			io = map[uint16]codec.IOItem{
+				fmxxx.CrashDetect: {Size: 1, Val: 2},
+				fmxxx.AxisX:       {Size: 2, Val: uint64(uint16(int16(-1000 - i)))},
+				fmxxx.AxisY:       {Size: 2, Val: 250},
+				fmxxx.AxisZ:       {Size: 2, Val: 0xFFE2}, // -30 mG
+			}
+		}
+		recs[i] = codec.AVLRecord{
+			Timestamp: time.UnixMilli(startMs + int64(i)*10).UTC(),
+			GPS:       codec.GPSData{Latitude: 20.96737, Longitude: -89.592586, Speed: 80, Satellites: 9},
+			TotalIO:   len(io),
+			IO:        io,
+		}
+	}
+	frame, err := codec.EncodeAVL(codec.AvlPacket{CodecID: codec.CodecID8E, Records: recs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := codec.AcquirePacketView()
+	if err := codec.DecodeAVLFrame(frame, v); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { codec.ReleasePacketView(v) })
+	return v
+}
+
+// storedTrace devuelve el trazo guardado para imei, esperando hasta wait.
+func storedTrace(t *testing.T, r *storetest.Redis, imei string, wait time.Duration) (pipeline.CrashTrace, bool) {
+	t.Helper()
+	id := imei + "-" + strconv.Itoa(crashStartMs)
+	deadline := time.Now().Add(wait)
+	for {
+		if data, ok := r.Get("crash:" + id); ok {
+			var ct pipeline.CrashTrace
+			if err := json.Unmarshal([]byte(data), &ct); err != nil {
+				t.Fatal(err)
+			}
+			if idx := r.List("dev:" + imei + ":crashes"); len(idx) != 1 || idx[0] != id {
+				t.Fatalf("crash index = %v", idx)
+			}
+			return ct, true
+		}
+		if time.Now().After(deadline) {
+			return pipeline.CrashTrace{}, false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func crashOpenFor(imei string) bool {
+	_, open := crashOpen.Load(imei)
+	return open
+}
+
+func TestCrashTraceClosedByNormalRecord(t *testing.T) {
+	const imei = "352093081452271"
+	r := storetest.Start(t)
+
+	// La ráfaga llega en dos paquetes
+	ProcessPacket(t.Context(), imei, crashPacket(t, crashStartMs, 3, true))
+	ProcessPacket(t.Context(), imei, crashPacket(t, crashStartMs+30, 2, true))
+	if !crashOpenFor(imei) {
+		t.Fatal("no trace open after crash samples")
+	}
+	if _, ok := storedTrace(t, r, imei, 0); ok {
+		t.Fatal("trace stored before the burst ended")
+	}
+
+	ProcessPacket(t.Context(), imei, crashPacket(t, crashStartMs+100, 1, false))
+	ct, ok := storedTrace(t, r, imei, 0)
+	if !ok {
+		t.Fatal("trace not stored after a normal record")
+	}
+	if crashOpenFor(imei) {
+		t.Fatal("trace still open")
+	}
+	if ct.IMEI != imei || ct.Kind != 2 || len(ct.Samples) != 5 {
+		t.Fatalf("trace = %s kind=%d samples=%d", ct.ID, ct.Kind, len(ct.Samples))
+	}
+	first, last := ct.Samples[0], ct.Samples[4]
+	if first.TimestampMs != crashStartMs || first.X != -1000 || first.Y != 250 || first.Z != -30 || first.Spd != 80 {
+		t.Fatalf("first sample = %+v", first)
+	}
+	if last.TimestampMs != crashStartMs+40 || last.X != -1001 {
+		t.Fatalf("last sample = %+v", last)
+	}
+	if want := time.UnixMilli(crashStartMs + 40).UTC().Format(time.RFC3339Nano); ct.End != want {
+		t.Fatalf("end = %s, want %s", ct.End, want)
+	}
+}
+
+func TestCrashTraceIdleTimeout(t *testing.T) {
+	const imei = "352093081452272"
+	r := storetest.Start(t)
+
+	old := crashTraceIdle
+	crashTraceIdle = 30 * time.Millisecond
+	t.Cleanup(func() { crashTraceIdle = old })
+
+	ProcessPacket(t.Context(), imei, crashPacket(t, crashStartMs, 4, true))
+	ct, ok := storedTrace(t, r, imei, 2*time.Second)
+	if !ok {
+		t.Fatal("trace not closed after the idle interval")
+	}
+	if len(ct.Samples) != 4 || crashOpenFor(imei) {
+		t.Fatalf("samples = %d, open = %v", len(ct.Samples), crashOpenFor(imei))
+	}
+}
+
+func TestCrashTraceMaxSamples(t *testing.T) {
+	const imei = "352093081452273"
+	r := storetest.Start(t)
+
+	// crashTraceMax-1 muestras directo al buffer; la que completa el tope
+	// llega por ProcessPacket y cierra el trazo.
+	v := crashPacket(t, crashStartMs, 1, true)
+	io := make(map[uint16]codec.IOItem)
+	v.Records[0].EachIO(func(id uint16, size int, val uint64, raw []byte) bool {
+		io[id] = codec.IOItem{Size: size, Val: val, Raw: raw}
+		return true
+	})
+	for i := 0; i < crashTraceMax-1; i++ {
+		if addCrashSample(imei, &v.Records[0], io) {
+			t.Fatalf("cap reached at sample %d", i+1)
+		}
+	}
+	if _, ok := storedTrace(t, r, imei, 0); ok {
+		t.Fatal("trace stored below the cap")
+	}
+
+	ProcessPacket(t.Context(), imei, crashPacket(t, crashStartMs+10, 1, true))
+	ct, ok := storedTrace(t, r, imei, 0)
+	if !ok {
+		t.Fatal("trace not closed at the cap")
+	}
+	if len(ct.Samples) != crashTraceMax || crashOpenFor(imei) {
+		t.Fatalf("samples = %d, open = %v", len(ct.Samples), crashOpenFor(imei))
+	}
+}
+
+func TestCrashTraceFlushedOnShutdown(t *testing.T) {
+	r := storetest.Start(t)
+	imeis := []string{"352093081452274", "352093081452275"}
+	for _, imei := range imeis {
+		ProcessPacket(t.Context(), imei, crashPacket(t, crashStartMs, 2, true))
+	}
+
+	flushAllCrashTraces(t.Context(), "shutdown")
+	for _, imei := range imeis {
+		ct, ok := storedTrace(t, r, imei, 0)
+		if !ok || len(ct.Samples) != 2 || crashOpenFor(imei) {
+			t.Fatalf("%s: stored = %v, samples = %d, open = %v", imei, ok, len(ct.Samples), crashOpenFor(imei))
+		}
+	}
+}
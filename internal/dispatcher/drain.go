@@ -0,0 +1,32 @@
+package dispatcher
+
+import "context"
+
+// workCtx es el contexto que reciben los trabajos encolados (frames ya
+// confirmados al equipo, ver Submit). Drain lo cancela si vence su plazo:
+// las llamadas a Redis en curso se cortan en lugar de seguir de largo.
+var workCtx, cancelWork = context.WithCancel(context.Background())
+
+// Drain detiene el pool de workers (desde acá los Submit se descartan),
+// espera hasta que venza ctx a que terminen lo ya encolado y cierra los
+// trazos de choque que hayan quedado abiertos. Si vuelve sin error ningún
+// worker sigue corriendo y se puede cerrar Redis; con error (plazo
+// vencido) el contexto de trabajo queda cancelado y alguno puede seguir
+// terminando lo suyo.
+func Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		stopWorkers()
+		workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		flushAllCrashTraces(ctx, "shutdown")
+		return nil
+	case <-ctx.Done():
+		cancelWork()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,60 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"codec-svr/internal/codec"
+	"codec-svr/internal/codec/fmxxx"
+	"codec-svr/internal/observability"
+	"codec-svr/internal/pipeline"
+	"codec-svr/internal/store"
+)
+
+// processDTC decodifica los códigos de falla del record, los deja en el
+// TrackingObject y emite un evento por cada código que apareció o se
+// borró respecto al conjunto guardado en dev:<imei>:dtc.
+func processDTC(ctx context.Context, imei string, ts time.Time, io map[uint16]codec.IOItem, tr *pipeline.TrackingObject) {
+	cur, ok := dtcFromIO(io)
+	if !ok {
+		return
+	}
+	tr.DTC = cur
+
+	key := "dev:" + imei + ":dtc"
+	var prev []string
+	if s := store.GetStringSafe(ctx, key); s != "" {
+		prev = strings.Split(s, ",")
+		sort.Strings(prev)
+	}
+
+	events := pipeline.DiffDTC(imei, ts, prev, cur)
+	if len(events) == 0 {
+		return
+	}
+	store.SaveStringSafe(ctx, key, strings.Join(cur, ","))
+
+	lg := observability.NewLogger()
+	for _, ev := range events {
+		fmt.Printf("[DTC] %s %s %s\n", imei, ev.Event, ev.Code)
+		for _, m := range pipeline.DTCToGRPC(ev) {
+			lg.Info("gRPC payload", "imei", imei, "payload", m)
+		}
+	}
+}
+
+// dtcFromIO devuelve los códigos activos del record. ok=false si el record
+// no informa nada de DTC; una cuenta de DTC en 0 sin lista vale como
+// "sin códigos" (se borraron todos).
+func dtcFromIO(io map[uint16]codec.IOItem) (codes []string, ok bool) {
+	if it, found := io[fmxxx.FaultCodes]; found && it.Raw != nil {
+		return fmxxx.DecodeDTC(it.Raw), true
+	}
+	if it, found := io[fmxxx.DTCCount]; found && it.Raw == nil && it.Val == 0 {
+		return nil, true
+	}
+	return nil, false
+}
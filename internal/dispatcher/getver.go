@@ -1,6 +1,7 @@
 package dispatcher
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -21,7 +22,7 @@ type DeviceVersion struct {
 	Raw      string
 }
 
-func HandleGetVerResponse(imei, text string) DeviceVersion {
+func HandleGetVerResponse(ctx context.Context, imei, text string) DeviceVersion {
 	t := strings.TrimSpace(text)
 
 	dv := DeviceVersion{
@@ -41,18 +42,18 @@ func HandleGetVerResponse(imei, text string) DeviceVersion {
 
 	// Save only if different
 	if dv.Firmware != "" &&
-		dv.Firmware != store.GetStringSafe("dev:"+imei+":fw") {
-		store.SaveStringSafe("dev:"+imei+":fw", dv.Firmware)
+		dv.Firmware != store.GetStringSafe(ctx, "dev:"+imei+":fw") {
+		store.SaveStringSafe(ctx, "dev:"+imei+":fw", dv.Firmware)
 	}
 
 	if dv.Model != "" &&
-		dv.Model != store.GetStringSafe("dev:"+imei+":model") {
-		store.SaveStringSafe("dev:"+imei+":model", dv.Model)
+		dv.Model != store.GetStringSafe(ctx, "dev:"+imei+":model") {
+		store.SaveStringSafe(ctx, "dev:"+imei+":model", dv.Model)
 	}
 
 	lt := strings.ToLower(dv.Raw)
 	if strings.Contains(lt, "ver:") && strings.Contains(lt, "hw:") {
-		store.SaveStringSafe("dev:"+imei+":getver_raw", dv.Raw)
+		store.SaveStringSafe(ctx, "dev:"+imei+":getver_raw", dv.Raw)
 	}
 
 	fmt.Printf("[GETVER] imei=%s model=%s fw=%s\n",
@@ -61,6 +62,6 @@ func HandleGetVerResponse(imei, text string) DeviceVersion {
 	return dv
 }
 
-func GetCachedModel(imei string) string {
-	return store.GetStringSafe("dev:" + imei + ":model")
+func GetCachedModel(ctx context.Context, imei string) string {
+	return store.GetStringSafe(ctx, "dev:"+imei+":model")
 }
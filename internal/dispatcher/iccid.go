@@ -2,6 +2,7 @@ package dispatcher
 
 import (
 	"codec-svr/internal/store"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"strconv"
@@ -41,12 +42,12 @@ func digitsOnly(s string) string {
 
 /* ------------------ Manejo de Respuestas ------------------ */
 
-func HandleICCIDResponse(imei, text string) {
+func HandleICCIDResponse(ctx context.Context, imei, text string) {
 	if strings.TrimSpace(text) == "" {
 		return
 	}
 
-	current := store.GetStringSafe("dev:" + imei + ":iccid")
+	current := store.GetStringSafe(ctx, "dev:"+imei+":iccid")
 	t := strings.TrimSpace(text)
 	lt := strings.ToLower(t)
 
@@ -73,7 +74,7 @@ func HandleICCIDResponse(imei, text string) {
 				val := digitsOnly(valRaw)
 
 				if len(val) >= 18 && val != current {
-					store.SaveStringSafe("dev:"+imei+":iccid", val)
+					store.SaveStringSafe(ctx, "dev:"+imei+":iccid", val)
 					fmt.Printf("[ICCID] stored primary imei=%s iccid=%s (raw=%q)\n", imei, val, valRaw)
 				}
 			}
@@ -106,7 +107,7 @@ func HandleICCIDResponse(imei, text string) {
 		newVal = digitsOnly(newVal)
 
 		if len(newVal) >= 18 && newVal != current {
-			store.SaveStringSafe("dev:"+imei+":iccid", newVal)
+			store.SaveStringSafe(ctx, "dev:"+imei+":iccid", newVal)
 			fmt.Printf("[ICCID] stored fallback imei=%s iccid=%s\n", imei, newVal)
 		}
 	}
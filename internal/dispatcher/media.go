@@ -0,0 +1,49 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"codec-svr/internal/codec"
+	"codec-svr/internal/observability"
+	"codec-svr/internal/pipeline"
+	"codec-svr/internal/store"
+)
+
+// recordLastEvent guarda el último record de evento (event IO != 0) del
+// equipo; los archivos de cámara que llegan después se enlazan con él.
+func recordLastEvent(ctx context.Context, imei string, rec *codec.RecordView) {
+	if rec.EventIOID == 0 {
+		return
+	}
+	v := rec.Time().Format(time.RFC3339) + "|" + strconv.Itoa(int(rec.EventIOID))
+	store.SaveStringSafe(ctx, "dev:"+imei+":last_event", v)
+}
+
+// LastEvent devuelve el timestamp y el event IO del último record de evento.
+func LastEvent(ctx context.Context, imei string) (time.Time, int, bool) {
+	s := store.GetStringSafe(ctx, "dev:"+imei+":last_event")
+	ts, io, ok := strings.Cut(s, "|")
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	id, _ := strconv.Atoi(io)
+	return t, id, true
+}
+
+// ProcessMedia emite el evento "media_available" de un archivo recibido.
+func ProcessMedia(mo *pipeline.MediaObject) {
+	fmt.Printf("[MEDIA] imei=%s source=%s event=%s path=%s bytes=%d\n", mo.IMEI, mo.Source, mo.EventDT, mo.Path, mo.Size)
+
+	lg := observability.NewLogger()
+	for _, m := range pipeline.MediaToGRPC(mo) {
+		lg.Info("gRPC payload", "imei", mo.IMEI, "payload", m)
+	}
+}
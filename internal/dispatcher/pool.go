@@ -0,0 +1,127 @@
+package dispatcher
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"codec-svr/internal/observability"
+)
+
+// Pool de workers por shard: todo el trabajo de un IMEI cae siempre en el
+// mismo shard y se procesa en orden de llegada. Las colas son acotadas;
+// con la cola llena Submit bloquea al lector TCP, que deja de leer y de
+// confirmar hasta que haya lugar. UDP usa TrySubmit: un solo loop lee
+// para todos los equipos y no puede esperar por uno.
+const (
+	DefaultShards     = 16
+	DefaultShardQueue = 256
+)
+
+type shard struct {
+	id    string
+	queue chan func(context.Context)
+}
+
+var (
+	poolOnce sync.Once
+	shards   []*shard
+
+	// stopMu protege stopped y el envío a las colas: tras stopWorkers las
+	// colas están cerradas y Submit descarta el trabajo.
+	stopMu  sync.RWMutex
+	stopped bool
+	workers sync.WaitGroup
+)
+
+// StartWorkers arranca el pool; sólo la primera llamada tiene efecto.
+// Submit lo arranca con los valores por defecto si nadie lo hizo antes.
+func StartWorkers(n, queue int) {
+	poolOnce.Do(func() {
+		if n <= 0 {
+			n = DefaultShards
+		}
+		if queue <= 0 {
+			queue = DefaultShardQueue
+		}
+		shards = make([]*shard, n)
+		for i := range shards {
+			sh := &shard{id: strconv.Itoa(i), queue: make(chan func(context.Context), queue)}
+			shards[i] = sh
+			observability.WorkerQueueDepth.WithLabelValues(sh.id).Set(0)
+			workers.Add(1)
+			go sh.run()
+		}
+	})
+}
+
+// Submit encola fn en el shard del IMEI; el worker la llama con el
+// contexto de trabajo (ver Drain). Una vez llamado Drain no hace nada
+// (p. ej. un timer de cierre de trazo que vence durante el apagado).
+func Submit(imei string, fn func(ctx context.Context)) {
+	sh := shardFor(imei)
+
+	stopMu.RLock()
+	defer stopMu.RUnlock()
+	if stopped {
+		return
+	}
+	select {
+	case sh.queue <- fn:
+	default:
+		observability.WorkerQueueFull.WithLabelValues(sh.id).Inc()
+		sh.queue <- fn // backpressure
+	}
+	observability.WorkerQueueDepth.WithLabelValues(sh.id).Set(float64(len(sh.queue)))
+}
+
+// TrySubmit es Submit sin esperar: con la cola del shard llena (o el pool
+// detenido) descarta fn, lo cuenta y devuelve false.
+func TrySubmit(imei string, fn func(ctx context.Context)) bool {
+	sh := shardFor(imei)
+
+	stopMu.RLock()
+	defer stopMu.RUnlock()
+	if stopped {
+		return false
+	}
+	select {
+	case sh.queue <- fn:
+	default:
+		observability.WorkerQueueDropped.WithLabelValues(sh.id).Inc()
+		return false
+	}
+	observability.WorkerQueueDepth.WithLabelValues(sh.id).Set(float64(len(sh.queue)))
+	return true
+}
+
+func shardFor(imei string) *shard {
+	StartWorkers(DefaultShards, DefaultShardQueue)
+
+	h := fnv.New32a()
+	h.Write([]byte(imei))
+	return shards[h.Sum32()%uint32(len(shards))]
+}
+
+// stopWorkers cierra las colas; los workers terminan lo encolado y salen.
+func stopWorkers() {
+	stopMu.Lock()
+	defer stopMu.Unlock()
+	if stopped {
+		return
+	}
+	stopped = true
+	for _, sh := range shards {
+		close(sh.queue)
+	}
+}
+
+func (sh *shard) run() {
+	defer workers.Done()
+	depth := observability.WorkerQueueDepth.WithLabelValues(sh.id)
+	for fn := range sh.queue {
+		depth.Set(float64(len(sh.queue)))
+		fn(workCtx)
+	}
+}
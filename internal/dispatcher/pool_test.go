@@ -0,0 +1,107 @@
+package dispatcher
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubmitKeepsPerIMEIOrder(t *testing.T) {
+	StartWorkers(4, 8)
+
+	const (
+		devices = 20
+		jobs    = 200
+	)
+	var (
+		mu  sync.Mutex
+		got = make(map[string][]int)
+		wg  sync.WaitGroup
+	)
+	wg.Add(devices * jobs)
+
+	// Un lector por equipo, todos a la vez y con colas chicas (backpressure)
+	var readers sync.WaitGroup
+	for d := 0; d < devices; d++ {
+		imei := "35209308145" + strconv.Itoa(1000+d)
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := 0; i < jobs; i++ {
+				Submit(imei, func(context.Context) {
+					defer wg.Done()
+					mu.Lock()
+					got[imei] = append(got[imei], i)
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	readers.Wait()
+	wg.Wait()
+
+	if len(got) != devices {
+		t.Fatalf("%d devices processed, want %d", len(got), devices)
+	}
+	for imei, seq := range got {
+		if len(seq) != jobs {
+			t.Fatalf("%s: %d jobs, want %d", imei, len(seq), jobs)
+		}
+		for i, v := range seq {
+			if v != i {
+				t.Fatalf("%s: job %d ran at position %d", imei, v, i)
+			}
+		}
+	}
+}
+
+func TestTrySubmitDropsWhenFull(t *testing.T) {
+	StartWorkers(4, 8)
+
+	const imei = "352093081452251"
+	sh := shardFor(imei)
+
+	// Trabar el worker del shard
+	release := make(chan struct{})
+	started := make(chan struct{})
+	Submit(imei, func(context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var ran sync.WaitGroup
+	var order []int
+	for i := 0; i < cap(sh.queue); i++ {
+		ran.Add(1)
+		if !TrySubmit(imei, func(context.Context) {
+			defer ran.Done()
+			order = append(order, i) // sólo el worker del shard escribe
+		}) {
+			t.Fatalf("job %d dropped with room in the queue", i)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		if TrySubmit(imei, func(context.Context) {}) {
+			t.Error("job accepted with a full queue")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("TrySubmit blocked on a full queue")
+	}
+
+	close(release)
+	ran.Wait()
+	for i, v := range order {
+		if v != i {
+			t.Fatalf("job %d ran at position %d", v, i)
+		}
+	}
+}
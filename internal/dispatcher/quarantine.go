@@ -0,0 +1,52 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"codec-svr/internal/codec"
+	"codec-svr/internal/observability"
+	"codec-svr/internal/store"
+)
+
+// QuarantineFrame cuenta el error de decodificación (por tipo, codec,
+// modelo y firmware) y guarda el frame crudo en cuarentena.
+func QuarantineFrame(ctx context.Context, imei string, frame []byte, err error) {
+	model := store.GetStringSafe(ctx, "dev:"+imei+":model")
+	fw := store.GetStringSafe(ctx, "dev:"+imei+":fw")
+
+	q := store.QuarantinedFrame{
+		IMEI:   imei,
+		Time:   time.Now().UTC(),
+		Kind:   string(codec.ErrorKind(err)),
+		Error:  err.Error(),
+		Offset: -1,
+		Record: -1,
+		Model:  model,
+		FWVer:  fw,
+		Hex:    hex.EncodeToString(frame),
+	}
+	var de *codec.DecodeError
+	if errors.As(err, &de) {
+		q.Offset, q.Record = de.Offset, de.Record
+		if de.Codec != 0 {
+			q.Codec = fmt.Sprintf("0x%02X", de.Codec)
+		}
+	}
+
+	observability.DecodeErrors.WithLabelValues(q.Kind, labelOr(q.Codec), labelOr(model), labelOr(fw)).Inc()
+
+	if err := store.QuarantineFrame(ctx, q); err != nil {
+		fmt.Printf("[ERROR] quarantine frame imei=%s: %v\n", imei, err)
+	}
+}
+
+func labelOr(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
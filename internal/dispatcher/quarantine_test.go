@@ -0,0 +1,59 @@
+package dispatcher
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"codec-svr/internal/codec"
+	"codec-svr/internal/observability"
+	"codec-svr/internal/store"
+	"codec-svr/internal/store/storetest"
+)
+
+func TestQuarantineFrame(t *testing.T) {
+	const imei = "352093081452261"
+	r := storetest.Start(t)
+	r.Set("dev:"+imei+":model", "FMC130")
+	r.Set("dev:"+imei+":fw", "03.27.07")
+
+	// AVL data Codec 8E cortado en el medio del primer record
+	data, _ := hex.DecodeString("8E010000016B412CEE000100000000000000")
+	_, derr := codec.ParseAVLData(data)
+	var de *codec.DecodeError
+	if !errors.As(derr, &de) {
+		t.Fatalf("err = %v, want a DecodeError", derr)
+	}
+	counter := observability.DecodeErrors.WithLabelValues(string(de.Kind), "0x8E", "FMC130", "03.27.07")
+	before := testutil.ToFloat64(counter)
+
+	QuarantineFrame(t.Context(), imei, data, derr)
+	// Sin DecodeError: sin codec ni posición, modelo desconocido
+	QuarantineFrame(t.Context(), "352093081452262", []byte{0xAA}, errors.New("boom"))
+
+	got, err := store.ListQuarantine(t.Context(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("%d frames quarantined, want 2", len(got))
+	}
+	q := got[1]
+	if q.IMEI != imei || q.Kind != string(de.Kind) || q.Codec != "0x8E" || q.Offset != de.Offset ||
+		q.Record != de.Record || q.Model != "FMC130" || q.FWVer != "03.27.07" || q.Hex != hex.EncodeToString(data) {
+		t.Fatalf("quarantined = %+v", q)
+	}
+	if q.Error != derr.Error() || q.Time.IsZero() {
+		t.Fatalf("quarantined error/time = %q/%v", q.Error, q.Time)
+	}
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Fatalf("decode error counter += %v, want 1", got)
+	}
+
+	plain := got[0]
+	if plain.Kind != string(codec.KindOther) || plain.Codec != "" || plain.Offset != -1 || plain.Record != -1 || plain.Model != "" {
+		t.Fatalf("plain error = %+v", plain)
+	}
+}
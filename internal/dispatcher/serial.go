@@ -0,0 +1,40 @@
+package dispatcher
+
+import (
+	"fmt"
+	"time"
+
+	"codec-svr/internal/codec"
+	"codec-svr/internal/observability"
+	"codec-svr/internal/pipeline"
+)
+
+// ProcessSerial reenvía un bloque de datos serie (Codec 15) con su
+// timestamp de equipo.
+func ProcessSerial(imei string, msg codec.Codec15Message) {
+	if msg.IMEI != "" && msg.IMEI != imei {
+		fmt.Printf("[SERIAL] imei mismatch session=%s frame=%s\n", imei, msg.IMEI)
+	}
+	fmt.Printf("[SERIAL] imei=%s ts=%s bytes=%d\n", imei, msg.Timestamp.Format(time.RFC3339), len(msg.Data))
+
+	so := pipeline.BuildSerial(imei, msg.Timestamp, msg.Data)
+
+	lg := observability.NewLogger()
+	for _, m := range pipeline.SerialToGRPC(so) {
+		lg.Info("gRPC payload", "imei", so.IMEI, "payload", m)
+	}
+}
+
+// ProcessCodec13 reenvía un mensaje unidireccional Codec 13 (puerto serie)
+// como payload serie. No pasa por HandleCommandResponses: no es respuesta
+// a ningún comando y su contenido es arbitrario.
+func ProcessCodec13(imei string, msg codec.Codec13Message) {
+	fmt.Printf("[SERIAL] codec13 imei=%s ts=%s bytes=%d\n", imei, msg.Timestamp.Format(time.RFC3339), len(msg.Text))
+
+	so := pipeline.BuildSerial(imei, msg.Timestamp, []byte(msg.Text))
+
+	lg := observability.NewLogger()
+	for _, m := range pipeline.SerialToGRPC(so) {
+		lg.Info("gRPC payload", "imei", so.IMEI, "payload", m)
+	}
+}
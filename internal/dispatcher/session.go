@@ -0,0 +1,28 @@
+package dispatcher
+
+import (
+	"fmt"
+	"time"
+
+	"codec-svr/internal/observability"
+	"codec-svr/internal/pipeline"
+)
+
+// ProcessTakeover cuenta y reenvía un segundo handshake del mismo IMEI.
+func ProcessTakeover(imei, policy, oldRemote, newRemote string) {
+	observability.SessionTakeovers.WithLabelValues(policy).Inc()
+	fmt.Printf("[SESSION] takeover imei=%s policy=%s old=%s new=%s\n", imei, policy, oldRemote, newRemote)
+
+	ev := pipeline.SessionEvent{
+		IMEI:      imei,
+		Datetime:  time.Now().UTC().Format(time.RFC3339),
+		Event:     "takeover",
+		Policy:    policy,
+		OldRemote: oldRemote,
+		NewRemote: newRemote,
+	}
+	lg := observability.NewLogger()
+	for _, m := range pipeline.SessionToGRPC(ev) {
+		lg.Info("gRPC payload", "imei", imei, "payload", m)
+	}
+}
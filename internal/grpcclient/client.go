@@ -30,8 +30,8 @@ func (g *GRPCClient) Close() {
 	g.conn.Close()
 }
 
-func (g *GRPCClient) SendData(deviceID, payload string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (g *GRPCClient) SendData(ctx context.Context, deviceID, payload string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	req := &forwarder.DataRequest{
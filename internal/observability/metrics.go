@@ -1,6 +1,7 @@
 package observability
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -14,21 +15,65 @@ var (
 		Name: "codec_tcp_connections_total",
 		Help: "Total de conexiones TCP aceptadas",
 	})
+	TCPCloses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "codec_tcp_closes_total",
+		Help: "Conexiones TCP cerradas por motivo (eof, handshake_timeout, idle_timeout, read_error, write_error, rejected, takeover)",
+	}, []string{"reason"})
 	HandshakeOK = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "codec_handshake_ok_total",
 		Help: "Total de handshakes IMEI ok",
 	})
+	SessionTakeovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "codec_session_takeovers_total",
+		Help: "Handshakes con un IMEI que ya tenía sesión viva, por política aplicada",
+	}, []string{"policy"})
 	PacketsRecv = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "codec_packets_received_total",
 		Help: "Total de paquetes AVL recibidos (frames)",
 	})
+	UDPPackets = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "codec_udp_packets_received_total",
+		Help: "Total de datagramas UDP AVL recibidos",
+	})
+	UDPDuplicates = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "codec_udp_duplicates_total",
+		Help: "Datagramas UDP retransmitidos (mismo AVL packet ID): se confirman sin reprocesar",
+	})
+	FrameResyncs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "codec_frame_resync_total",
+		Help: "Resincronizaciones del stream TCP por motivo (bad_preamble, bad_imei, oversize, unknown_codec, bad_crc)",
+	}, []string{"reason"})
+	FrameResyncBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "codec_frame_resync_bytes_total",
+		Help: "Bytes descartados al resincronizar el stream TCP",
+	})
+	WorkerQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "codec_worker_queue_depth",
+		Help: "Trabajos encolados por shard del pool de workers",
+	}, []string{"shard"})
+	WorkerQueueFull = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "codec_worker_queue_full_total",
+		Help: "Veces que un lector esperó por cola llena (backpressure) por shard",
+	}, []string{"shard"})
+	WorkerQueueDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "codec_worker_queue_dropped_total",
+		Help: "Trabajos UDP descartados sin ACK por cola llena, por shard",
+	}, []string{"shard"})
 	RecordsAck = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "codec_records_ack_total",
 		Help: "Total de registros AVL confirmados (ACK a Teltonika)",
 	})
 	ParseErrors = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "codec_parse_errors_total",
-		Help: "Errores al parsear Codec8E",
+		Help: "Errores al parsear frames AVL (detalle en codec_decode_errors_total)",
+	})
+	DecodeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "codec_decode_errors_total",
+		Help: "Frames AVL en cuarentena por tipo de error, codec, modelo y firmware",
+	}, []string{"kind", "codec", "model", "fw"})
+	CRCErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "codec_crc_mismatch_total",
+		Help: "Frames AVL descartados por CRC-16/IBM inválido (sin ACK)",
 	})
 	RedisSetErrors = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "codec_redis_set_errors_total",
@@ -49,11 +94,19 @@ func ObserveParseLatency(start time.Time) {
 	ParseLatency.Observe(time.Since(start).Seconds())
 }
 
-func StartMetricsServer(port string) {
+// StartMetricsServer sirve /metrics y /healthz hasta que se cancele ctx.
+func StartMetricsServer(ctx context.Context, port string) {
 	http.Handle("/metrics", promhttp.Handler())
 	http.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(200)
 		_, _ = w.Write([]byte("ok"))
 	})
-	_ = http.ListenAndServe(":"+port, nil)
+	srv := &http.Server{Addr: ":" + port}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(sctx)
+	}()
+	_ = srv.ListenAndServe()
 }
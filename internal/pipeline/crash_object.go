@@ -0,0 +1,24 @@
+package pipeline
+
+// CrashSample es una muestra del trazo de choque: acelerómetro (mG) y GPS
+// del mismo instante.
+type CrashSample struct {
+	TimestampMs int64   `json:"t"`
+	X           int     `json:"x"`
+	Y           int     `json:"y"`
+	Z           int     `json:"z"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Spd         int     `json:"spd"`
+}
+
+// CrashTrace es el trazo completo de un choque, reensamblado de la ráfaga
+// de records que el equipo manda con el IO 247.
+type CrashTrace struct {
+	ID      string        `json:"id"` // <imei>-<ms del primer sample>
+	IMEI    string        `json:"imei"`
+	Kind    int           `json:"kind"` // valor del IO 247 (1..6)
+	Start   string        `json:"start"`
+	End     string        `json:"end"`
+	Samples []CrashSample `json:"samples"`
+}
@@ -0,0 +1,16 @@
+package pipeline
+
+// Eventos de códigos de falla (DTC) OBD-II / CAN.
+const (
+	DTCAppeared = "appeared"
+	DTCCleared  = "cleared"
+)
+
+// DTCEvent informa que un código de falla apareció o se borró respecto al
+// último conjunto conocido del equipo.
+type DTCEvent struct {
+	IMEI     string `json:"imei"`
+	Datetime string `json:"dt"`    // timestamp del record que trajo el cambio
+	Event    string `json:"event"` // DTCAppeared | DTCCleared
+	Code     string `json:"code"`  // p.ej. "P0107"
+}
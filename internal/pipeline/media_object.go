@@ -0,0 +1,14 @@
+package pipeline
+
+// MediaObject describe un archivo de cámara (DualCam / ADAS) ya recibido
+// completo y guardado en disco.
+type MediaObject struct {
+	IMEI     string `json:"imei"`
+	Datetime string `json:"dt"`       // cuándo terminó la transferencia
+	EventDT  string `json:"event_dt"` // timestamp del record AVL que la disparó
+	EventIO  int    `json:"event_io"` // event IO ID de ese record (0 si no se conoce)
+	Source   string `json:"source"`   // ruta pedida al equipo, p.ej. "%photof"
+	Kind     string `json:"kind"`     // "photo" | "video"
+	Path     string `json:"path"`     // ruta local del archivo
+	Size     int64  `json:"size"`
+}
@@ -1,8 +1,14 @@
 package pipeline
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"strconv"
 	"time"
+	"unicode"
+
+	"codec-svr/internal/codec"
+	"codec-svr/internal/codec/fmxxx"
 )
 
 // ---------------- helpers de coordenadas / fix ----------------
@@ -39,31 +45,106 @@ func DecideMsgType(isBatch bool, ts time.Time) int {
 
 func BuildTracking(
 	imei string,
-	dt time.Time,
-	lat, lon float64,
-	spd, crs, sats int,
+	rec *codec.RecordView,
 	perm map[string]uint64,
 	msgType int,
 	model, fw, iccid string,
 
 ) *TrackingObject {
+	gps := rec.GPS()
+	gen := ""
+	if g, ok := rec.GenerationType(); ok {
+		gen = g.String()
+	}
 	return &TrackingObject{
 		IMEI:     imei,
 		Model:    model,
 		FWVer:    fw,
 		Iccid:    iccid,
-		Datetime: dt.Format(time.RFC3339),
-		Lat:      lat,
-		Lon:      lon,
-		Spd:      spd,
-		Crs:      crs,
-		Sats:     sats,
+		Datetime: rec.Time().Format(time.RFC3339),
+		Lat:      gps.Latitude,
+		Lon:      gps.Longitude,
+		Spd:      gps.Speed,
+		Crs:      gps.Angle,
+		Sats:     gps.Satellites,
 		PermIO:   perm, // plano: "239"->1, "1"->0, etc. (desde Redis)
+		Gen:      gen,
 		MsgType:  msgType,
-		Fix:      CalcFix(sats, lat, lon),
+		Fix:      CalcFix(gps.Satellites, gps.Latitude, gps.Longitude),
+	}
+}
+
+// NamedIO traduce el estado perm IO a valores con nombre y escala según el
+// catálogo. El tamaño real del IO se toma del record cuando viene en él
+// (importa para extender el signo); si no, del catálogo.
+func NamedIO(cat *fmxxx.Catalog, model string, perm map[string]uint64, recIO map[uint16]codec.IOItem) map[string]fmxxx.Value {
+	if cat == nil || len(perm) == 0 {
+		return nil
+	}
+	out := make(map[string]fmxxx.Value, len(perm))
+	for k, raw := range perm {
+		id, err := strconv.ParseUint(k, 10, 16)
+		if err != nil {
+			continue
+		}
+		el, ok := cat.Lookup(model, uint16(id))
+		if !ok {
+			continue
+		}
+		size := 0
+		if it, ok := recIO[uint16(id)]; ok && it.Raw == nil {
+			size = it.Size
+		}
+		out[el.Name] = el.Scale(raw, size)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// ApplyNX decodifica los X-bytes conocidos del record (textos, ISO 6709 y
+// beacons BLE) y los agrega al TrackingObject.
+func ApplyNX(tr *TrackingObject, recIO map[uint16]codec.IOItem) {
+	for id, it := range recIO {
+		if it.Raw == nil {
+			continue
+		}
+		if name, ok := fmxxx.StringName(id); ok {
+			if v := fmxxx.DecodeString(it.Raw); v != "" {
+				if tr.Strings == nil {
+					tr.Strings = map[string]string{}
+				}
+				tr.Strings[name] = v
+			}
+			continue
+		}
+		switch id {
+		case fmxxx.ISO6709Coord:
+			if c, err := fmxxx.DecodeISO6709(it.Raw); err == nil {
+				tr.Coord = &c
+			}
+		case fmxxx.BLEBeacons, fmxxx.AdvBLEBeacon:
+			// aunque venga truncado, se conservan los beacons ya leídos
+			bs, _ := fmxxx.DecodeBeacons(id, it.Raw)
+			tr.Beacons = append(tr.Beacons, bs...)
+		}
 	}
 }
 
+// ApplyEye agrupa por slot los IO de sensores BLE/EYE del record.
+// Sólo se usan los IO presentes en el record: una lectura vieja de Redis
+// no describe al sensor ahora.
+func ApplyEye(tr *TrackingObject, recIO map[uint16]codec.IOItem) {
+	tr.Eye = fmxxx.DecodeEye(func(id uint16) (uint64, int, bool) {
+		it, ok := recIO[id]
+		if !ok || it.Raw != nil {
+			return 0, 0, false
+		}
+		return it.Val, it.Size, true
+	})
+}
+
 // ---------------- agrupación perm_io y salida gRPC ---------------
 
 // agrupa el map plano ("239"->1, "66"->12450, ...) en:
@@ -128,6 +209,13 @@ func ToGRPC(tr *TrackingObject) []string {
 		Crs     int                          `json:"crs"`
 		Sats    int                          `json:"sats"`
 		PermIO  map[string]map[string]uint64 `json:"perm_io"`
+		IO      map[string]fmxxx.Value       `json:"io,omitempty"`
+		Strings map[string]string            `json:"nx_str,omitempty"`
+		Coord   *fmxxx.ISO6709               `json:"iso6709,omitempty"`
+		Beacons []fmxxx.Beacon               `json:"beacons,omitempty"`
+		Eye     []fmxxx.EyeSensor            `json:"eye,omitempty"`
+		DTC     []string                     `json:"dtc,omitempty"`
+		Gen     string                       `json:"gen,omitempty"`
 		MsgType int                          `json:"msg_type"`
 		Fix     int                          `json:"fix"`
 		Model   string                       `json:"model,omitempty"`
@@ -144,6 +232,13 @@ func ToGRPC(tr *TrackingObject) []string {
 		Crs:     tr.Crs,
 		Sats:    tr.Sats,
 		PermIO:  groupPermIO(tr.PermIO),
+		IO:      tr.IO,
+		Strings: tr.Strings,
+		Coord:   tr.Coord,
+		Beacons: tr.Beacons,
+		Eye:     tr.Eye,
+		DTC:     tr.DTC,
+		Gen:     tr.Gen,
 		MsgType: tr.MsgType,
 		Fix:     tr.Fix,
 		Model:   tr.Model,
@@ -158,3 +253,193 @@ func ToGRPC(tr *TrackingObject) []string {
 	}
 	return []string{string(b)}
 }
+
+// ---------------- eventos DTC ----------------
+
+// DiffDTC compara el conjunto anterior de códigos con el actual (ambos
+// ordenados) y arma un evento por código que apareció o se borró.
+func DiffDTC(imei string, ts time.Time, prev, cur []string) []DTCEvent {
+	dt := ts.Format(time.RFC3339)
+	var out []DTCEvent
+	i, j := 0, 0
+	for i < len(prev) || j < len(cur) {
+		switch {
+		case j >= len(cur) || (i < len(prev) && prev[i] < cur[j]):
+			out = append(out, DTCEvent{IMEI: imei, Datetime: dt, Event: DTCCleared, Code: prev[i]})
+			i++
+		case i >= len(prev) || cur[j] < prev[i]:
+			out = append(out, DTCEvent{IMEI: imei, Datetime: dt, Event: DTCAppeared, Code: cur[j]})
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// DTCToGRPC arma el JSON de un evento DTC.
+func DTCToGRPC(ev DTCEvent) []string {
+	type payload struct {
+		Type  string `json:"type"`
+		IMEI  string `json:"imei"`
+		DT    string `json:"dt"`
+		Event string `json:"event"`
+		Code  string `json:"code"`
+	}
+
+	b, err := json.Marshal(payload{
+		Type:  "dtc",
+		IMEI:  ev.IMEI,
+		DT:    ev.Datetime,
+		Event: ev.Event,
+		Code:  ev.Code,
+	})
+	if err != nil {
+		return []string{`{"error":"json_marshal_failed"}`}
+	}
+	return []string{string(b)}
+}
+
+// ---------------- eventos de choque ----------------
+
+// CrashToGRPC arma el evento de choque que se reenvía; el trazo completo
+// queda guardado aparte y se referencia por crash_id.
+func CrashToGRPC(ct *CrashTrace) []string {
+	type payload struct {
+		Type       string  `json:"type"`
+		IMEI       string  `json:"imei"`
+		DT         string  `json:"dt"`
+		CrashID    string  `json:"crash_id"`
+		Kind       int     `json:"kind"`
+		Samples    int     `json:"samples"`
+		DurationMs int64   `json:"duration_ms"`
+		Lat        float64 `json:"lat"`
+		Lon        float64 `json:"lon"`
+	}
+
+	pl := payload{
+		Type:    "crash",
+		IMEI:    ct.IMEI,
+		DT:      ct.Start,
+		CrashID: ct.ID,
+		Kind:    ct.Kind,
+		Samples: len(ct.Samples),
+	}
+	if n := len(ct.Samples); n > 0 {
+		pl.DurationMs = ct.Samples[n-1].TimestampMs - ct.Samples[0].TimestampMs
+		// última posición válida del trazo
+		for i := n - 1; i >= 0; i-- {
+			if coordsValid(ct.Samples[i].Lat, ct.Samples[i].Lon) {
+				pl.Lat, pl.Lon = ct.Samples[i].Lat, ct.Samples[i].Lon
+				break
+			}
+		}
+	}
+
+	b, err := json.Marshal(pl)
+	if err != nil {
+		return []string{`{"error":"json_marshal_failed"}`}
+	}
+	return []string{string(b)}
+}
+
+// ---------------- archivos de cámara ----------------
+
+// MediaToGRPC arma el evento "media_available" de un archivo de cámara.
+func MediaToGRPC(mo *MediaObject) []string {
+	type payload struct {
+		Type    string `json:"type"`
+		IMEI    string `json:"imei"`
+		DT      string `json:"dt"`
+		EventDT string `json:"event_dt"`
+		EventIO int    `json:"event_io,omitempty"`
+		Source  string `json:"source"`
+		Kind    string `json:"kind"`
+		Path    string `json:"path"`
+		Size    int64  `json:"size"`
+	}
+
+	b, err := json.Marshal(payload{
+		Type:    "media_available",
+		IMEI:    mo.IMEI,
+		DT:      mo.Datetime,
+		EventDT: mo.EventDT,
+		EventIO: mo.EventIO,
+		Source:  mo.Source,
+		Kind:    mo.Kind,
+		Path:    mo.Path,
+		Size:    mo.Size,
+	})
+	if err != nil {
+		return []string{`{"error":"json_marshal_failed"}`}
+	}
+	return []string{string(b)}
+}
+
+// ---------------- eventos de sesión ----------------
+
+// SessionToGRPC arma el JSON de un evento de sesión.
+func SessionToGRPC(ev SessionEvent) []string {
+	type payload struct {
+		Type string `json:"type"`
+		SessionEvent
+	}
+
+	b, err := json.Marshal(payload{Type: "session", SessionEvent: ev})
+	if err != nil {
+		return []string{`{"error":"json_marshal_failed"}`}
+	}
+	return []string{string(b)}
+}
+
+// ---------------- datos serie (Codec 15) ----------------
+
+func BuildSerial(imei string, dt time.Time, data []byte) *SerialObject {
+	return &SerialObject{
+		IMEI:     imei,
+		Datetime: dt.Format(time.RFC3339),
+		Data:     data,
+	}
+}
+
+// SerialToGRPC arma el JSON de un bloque serie. El payload viaja en hex;
+// si además es texto imprimible (p.ej. una báscula en modo ASCII) se
+// incluye tal cual en "ascii".
+func SerialToGRPC(so *SerialObject) []string {
+	type payload struct {
+		Type  string `json:"type"`
+		IMEI  string `json:"imei"`
+		DT    string `json:"dt"`
+		Hex   string `json:"hex"`
+		ASCII string `json:"ascii,omitempty"`
+	}
+
+	pl := payload{
+		Type: "serial",
+		IMEI: so.IMEI,
+		DT:   so.Datetime,
+		Hex:  hex.EncodeToString(so.Data),
+	}
+	if isPrintable(so.Data) {
+		pl.ASCII = string(so.Data)
+	}
+
+	b, err := json.Marshal(pl)
+	if err != nil {
+		return []string{`{"error":"json_marshal_failed"}`}
+	}
+	return []string{string(b)}
+}
+
+func isPrintable(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if c > unicode.MaxASCII || (!unicode.IsPrint(rune(c)) && !unicode.IsSpace(rune(c))) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffDTC(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ev := func(event, code string) DTCEvent {
+		return DTCEvent{IMEI: "352093081452251", Datetime: "2024-03-01T12:00:00Z", Event: event, Code: code}
+	}
+
+	for _, tc := range []struct {
+		name      string
+		prev, cur []string
+		want      []DTCEvent
+	}{
+		{"no change", []string{"P0107", "U0100"}, []string{"P0107", "U0100"}, nil},
+		{"first codes", nil, []string{"P0107", "P0300"}, []DTCEvent{ev(DTCAppeared, "P0107"), ev(DTCAppeared, "P0300")}},
+		{"all cleared", []string{"C0123"}, nil, []DTCEvent{ev(DTCCleared, "C0123")}},
+		{"mixed", []string{"B0ABC", "P0107", "U0100"}, []string{"C0123", "P0107", "P0300"}, []DTCEvent{
+			ev(DTCCleared, "B0ABC"),
+			ev(DTCAppeared, "C0123"),
+			ev(DTCAppeared, "P0300"),
+			ev(DTCCleared, "U0100"),
+		}},
+		{"both empty", nil, nil, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := DiffDTC("352093081452251", ts, tc.prev, tc.cur)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
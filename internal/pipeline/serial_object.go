@@ -0,0 +1,9 @@
+package pipeline
+
+// SerialObject transporta datos de terceros recibidos por el puerto serie
+// del equipo (Codec 15: lectores RFID, básculas, etc.).
+type SerialObject struct {
+	IMEI     string `json:"imei"`
+	Datetime string `json:"dt"`   // timestamp del equipo
+	Data     []byte `json:"data"` // payload serie sin interpretar
+}
@@ -0,0 +1,12 @@
+package pipeline
+
+// SessionEvent informa un cambio de sesión TCP de un equipo (p.ej. un
+// segundo handshake con el mismo IMEI).
+type SessionEvent struct {
+	IMEI      string `json:"imei"`
+	Datetime  string `json:"dt"`
+	Event     string `json:"event"`  // "takeover"
+	Policy    string `json:"policy"` // close_old | reject_new | allow
+	OldRemote string `json:"old_remote"`
+	NewRemote string `json:"new_remote"`
+}
@@ -1,5 +1,7 @@
 package pipeline
 
+import "codec-svr/internal/codec/fmxxx"
+
 type TrackingObject struct {
 	IMEI     string `json:"imei"`
 	Model    string `json:"model,omitempty"`
@@ -15,6 +17,24 @@ type TrackingObject struct {
 
 	PermIO map[string]uint64 `json:"perm_io"`
 
+	// IO con nombre, escalados según el catálogo (p.ej. "ext_voltage": 12.45 V)
+	IO map[string]fmxxx.Value `json:"io,omitempty"`
+
+	// X-bytes decodificados del record (sólo si vienen en él)
+	Strings map[string]string `json:"nx_str,omitempty"`
+	Coord   *fmxxx.ISO6709    `json:"iso6709,omitempty"`
+
+	// Beacons BLE vistos en este record (IO 385 / 548)
+	Beacons []fmxxx.Beacon `json:"beacons,omitempty"`
+
+	// Sensores EYE / BLE agrupados por slot
+	Eye []fmxxx.EyeSensor `json:"eye,omitempty"`
+
+	// Códigos de falla activos (OBD-II / CAN), si el record los trae
+	DTC []string `json:"dtc,omitempty"`
+
+	Gen string `json:"gen,omitempty"` // generation type (Codec 16)
+
 	MsgType int `json:"msg_type"` // 1=live, 0=buffer
 	Fix     int `json:"fix"`      // 1 si sats>3 y coords válidas
 }
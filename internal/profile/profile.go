@@ -0,0 +1,216 @@
+package profile
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"codec-svr/internal/store"
+)
+
+// Estrategias para obtener el ICCID de la SIM.
+const (
+	ICCIDGetIMEICCID = "getimeiccid" // comando getimeiccid
+	ICCIDGetParam    = "getparam"    // getparam 219,220,221 (familia 650)
+	ICCIDFromAVL     = "avl_io"      // el equipo ya manda IO 219/220/221 en los records
+	ICCIDNone        = "none"
+)
+
+// Profile agrupa lo que depende del modelo/firmware de un equipo.
+type Profile struct {
+	Name   string   `json:"name"`
+	Models []string `json:"models"`           // prefijos de modelo (sin mayúsculas/minúsculas); "*" = cualquiera
+	MinFW  string   `json:"min_fw,omitempty"` // inclusive, p.ej. "03.27.00"
+	MaxFW  string   `json:"max_fw,omitempty"` // inclusive
+
+	CatalogModel string   `json:"catalog_model,omitempty"` // clave de overrides del catálogo IO; vacío = el modelo reportado
+	ICCID        string   `json:"iccid"`                   // estrategia ICCID*
+	Commands     []string `json:"commands,omitempty"`      // comandos permitidos; vacío = todos
+	PermIO       []uint16 `json:"perm_io,omitempty"`       // IO que se guardan como perm IO; vacío = todos
+}
+
+// Allows indica si el perfil permite enviar el comando cmd.
+func (p Profile) Allows(cmd string) bool {
+	if len(p.Commands) == 0 {
+		return true
+	}
+	for _, c := range p.Commands {
+		if strings.EqualFold(c, cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepsPermIO indica si el IO id entra en la whitelist de perm IO.
+func (p Profile) KeepsPermIO(id uint16) bool {
+	if len(p.PermIO) == 0 {
+		return true
+	}
+	for _, v := range p.PermIO {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// CatalogKey devuelve el modelo a usar para los overrides del catálogo IO.
+func (p Profile) CatalogKey(model string) string {
+	if p.CatalogModel != "" {
+		return p.CatalogModel
+	}
+	return model
+}
+
+// ---------------- registro de perfiles ----------------
+
+//go:embed profiles.json
+var defaultProfilesJSON []byte
+
+var (
+	mu       sync.RWMutex
+	profiles = mustParse(defaultProfilesJSON)
+)
+
+func mustParse(b []byte) []Profile {
+	ps, err := Parse(b)
+	if err != nil {
+		panic("profile: embedded profiles: " + err.Error())
+	}
+	return ps
+}
+
+// Parse lee una lista JSON de perfiles. El orden importa: gana el primero que aplique.
+func Parse(b []byte) ([]Profile, error) {
+	var ps []Profile
+	if err := json.Unmarshal(b, &ps); err != nil {
+		return nil, fmt.Errorf("profiles: %w", err)
+	}
+	for i, p := range ps {
+		if p.Name == "" || len(p.Models) == 0 {
+			return nil, fmt.Errorf("profiles: entry %d needs name and models", i)
+		}
+		switch p.ICCID {
+		case "":
+			ps[i].ICCID = ICCIDGetIMEICCID
+		case ICCIDGetIMEICCID, ICCIDGetParam, ICCIDFromAVL, ICCIDNone:
+		default:
+			return nil, fmt.Errorf("profiles: %s: unknown iccid strategy %q", p.Name, p.ICCID)
+		}
+	}
+	return ps, nil
+}
+
+// Load reemplaza los perfiles embebidos por los de un archivo JSON.
+func Load(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ps, err := Parse(b)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	profiles = ps
+	mu.Unlock()
+	return nil
+}
+
+// Resolve elige el perfil para un modelo y firmware. Con modelo vacío
+// (getver aún sin respuesta) sólo aplican perfiles "*".
+func Resolve(model, fw string) Profile {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, p := range profiles {
+		if matchesModel(p.Models, model) && inRange(fw, p.MinFW, p.MaxFW) {
+			return p
+		}
+	}
+	return Profile{Name: "builtin", ICCID: ICCIDGetIMEICCID}
+}
+
+// ForIMEI resuelve el perfil a partir del modelo y firmware cacheados por getver.
+func ForIMEI(ctx context.Context, imei string) Profile {
+	model := store.GetStringSafe(ctx, "dev:"+imei+":model")
+	fw := store.GetStringSafe(ctx, "dev:"+imei+":fw")
+	return Resolve(model, fw)
+}
+
+func matchesModel(patterns []string, model string) bool {
+	m := strings.ToUpper(strings.TrimSpace(model))
+	for _, p := range patterns {
+		if p == "*" {
+			return true
+		}
+		if m != "" && strings.HasPrefix(m, strings.ToUpper(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange compara versiones tipo "03.27.07_E Rev:01" por sus segmentos numéricos.
+// Sin firmware conocido sólo aplican perfiles sin rango.
+func inRange(fw, min, max string) bool {
+	if min == "" && max == "" {
+		return true
+	}
+	v := fwSegments(fw)
+	if v == nil {
+		return false
+	}
+	if min != "" && compareSegments(v, fwSegments(min)) < 0 {
+		return false
+	}
+	if max != "" && compareSegments(v, fwSegments(max)) > 0 {
+		return false
+	}
+	return true
+}
+
+func fwSegments(fw string) []int {
+	fw = strings.TrimSpace(fw)
+	if i := strings.IndexAny(fw, "_ "); i >= 0 {
+		fw = fw[:i]
+	}
+	if fw == "" {
+		return nil
+	}
+	parts := strings.Split(fw, ".")
+	out := make([]int, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+func compareSegments(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
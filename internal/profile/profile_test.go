@@ -0,0 +1,112 @@
+package profile
+
+import "testing"
+
+// useProfiles reemplaza los perfiles cargados durante el test.
+func useProfiles(t *testing.T, js string) {
+	t.Helper()
+	ps, err := Parse([]byte(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	old := profiles
+	profiles = ps
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		profiles = old
+		mu.Unlock()
+	})
+}
+
+func TestResolveEmbedded(t *testing.T) {
+	for _, tc := range []struct {
+		model   string
+		name    string
+		iccid   string
+		catalog string
+	}{
+		{"FMC650", "fm650", ICCIDGetParam, "FMC650"},
+		{"FMM650", "fm650", ICCIDGetParam, "FMM650"},
+		// Los 640 siguen con getimeiccid, como antes de los perfiles
+		{"FMB640", "default", ICCIDGetIMEICCID, "FMB640"},
+		{"FMC640", "default", ICCIDGetIMEICCID, "FMC640"},
+		{"FMC003", "fmb003", ICCIDGetIMEICCID, "FMB003"},
+		{" fmb920 ", "fm9xx", ICCIDGetIMEICCID, " fmb920 "},
+		{"FMB120", "fm1xx", ICCIDGetIMEICCID, "FMB120"},
+		{"FMT100", "default", ICCIDGetIMEICCID, "FMT100"},
+		{"", "default", ICCIDGetIMEICCID, ""},
+	} {
+		p := Resolve(tc.model, "03.27.07")
+		if p.Name != tc.name || p.ICCID != tc.iccid || p.CatalogKey(tc.model) != tc.catalog {
+			t.Errorf("Resolve(%q) = %s/%s/%s, want %s/%s/%s", tc.model,
+				p.Name, p.ICCID, p.CatalogKey(tc.model), tc.name, tc.iccid, tc.catalog)
+		}
+	}
+}
+
+func TestResolveFirmwareRange(t *testing.T) {
+	useProfiles(t, `[
+		{"name": "old", "models": ["FMB9"], "max_fw": "03.25.99", "iccid": "none"},
+		{"name": "new", "models": ["FMB9"], "min_fw": "03.27.00", "iccid": "avl_io"},
+		{"name": "fmb9", "models": ["FMB9"]}
+	]`)
+
+	for _, tc := range []struct {
+		fw   string
+		want string
+	}{
+		{"03.25.14_E Rev:03", "old"},
+		{"03.25.99", "old"},
+		{"03.26.01", "fmb9"},
+		{"03.27.00", "new"},
+		{"03.27.07_E Rev:01", "new"},
+		{"3.28", "new"},
+		{"", "fmb9"},     // firmware desconocido: sólo perfiles sin rango
+		{"beta", "fmb9"}, // firmware no numérico
+	} {
+		if p := Resolve("FMB920", tc.fw); p.Name != tc.want {
+			t.Errorf("Resolve(FMB920, %q) = %s, want %s", tc.fw, p.Name, tc.want)
+		}
+	}
+}
+
+func TestResolveFallback(t *testing.T) {
+	useProfiles(t, `[{"name": "fm650", "models": ["FMC650"], "iccid": "getparam"}]`)
+
+	// Sin perfil "*" queda el builtin
+	for _, model := range []string{"FMB920", ""} {
+		p := Resolve(model, "")
+		if p.Name != "builtin" || p.ICCID != ICCIDGetIMEICCID || !p.Allows("getgps") || !p.KeepsPermIO(66) {
+			t.Errorf("Resolve(%q) = %+v", model, p)
+		}
+	}
+	// El prefijo no matchea en el medio del modelo
+	if p := Resolve("XFMC650", ""); p.Name != "builtin" {
+		t.Errorf("Resolve(XFMC650) = %s", p.Name)
+	}
+}
+
+func TestProfileRestrictions(t *testing.T) {
+	p := Profile{Commands: []string{"getver", "GETGPS"}, PermIO: []uint16{66, 239}}
+	if !p.Allows("getgps") || !p.Allows("getver") || p.Allows("cpureset") {
+		t.Error("command whitelist")
+	}
+	if !p.KeepsPermIO(239) || p.KeepsPermIO(21) {
+		t.Error("perm IO whitelist")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, js := range []string{
+		`{}`,
+		`[{"models": ["*"]}]`,
+		`[{"name": "x"}]`,
+		`[{"name": "x", "models": ["*"], "iccid": "sms"}]`,
+	} {
+		if _, err := Parse([]byte(js)); err == nil {
+			t.Errorf("Parse(%s) accepted", js)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"codec-svr/internal/session"
+	"codec-svr/internal/store"
+)
+
+// StartAdmin sirve los endpoints de administración en addr, con su propio
+// mux (no el de /metrics), hasta que se cancele ctx. Con token != "" cada
+// pedido debe traer "Authorization: Bearer <token>".
+func StartAdmin(ctx context.Context, addr, token string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/quarantine", requireToken(token, handleQuarantine))
+	mux.HandleFunc("/admin/sessions", requireToken(token, handleSessions))
+	mux.HandleFunc("/admin/command", requireToken(token, handleCommand))
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(sctx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// requireToken rechaza con 401 los pedidos sin el bearer token esperado.
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return next
+	}
+	want := []byte("Bearer " + token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// GET /admin/quarantine?limit=N → frames en cuarentena, más recientes primero.
+func handleQuarantine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	frames, err := store.ListQuarantine(r.Context(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, http.StatusOK, frames)
+}
+
+// GET /admin/sessions → equipos conectados.
+func handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, session.List())
+}
+
+// POST /admin/command {"imei":"...","command":"getgps","timeout_s":30,"codec":14}
+// → manda el comando por Codec 12 (o 14, dirigido al IMEI) y devuelve la
+// respuesta del equipo.
+func handleCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		IMEI     string `json:"imei"`
+		Command  string `json:"command"`
+		TimeoutS int    `json:"timeout_s"`
+		Codec    int    `json:"codec"` // 12 (por defecto) o 14
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	req.Command = strings.TrimSpace(req.Command)
+	if req.IMEI == "" || req.Command == "" {
+		http.Error(w, "imei and command are required", http.StatusBadRequest)
+		return
+	}
+	timeout := session.CommandTimeout
+	if req.TimeoutS > 0 {
+		timeout = min(time.Duration(req.TimeoutS)*time.Second, session.MaxCommandTimeout)
+	}
+
+	var resp string
+	var err error
+	switch req.Codec {
+	case 0, 12:
+		resp, err = session.SendCommandTimeout(req.IMEI, req.Command, timeout)
+	case 14:
+		resp, err = session.SendCommand14(req.IMEI, req.Command, timeout)
+	default:
+		http.Error(w, "codec must be 12 or 14", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case errors.Is(err, session.ErrOffline):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, session.ErrTimeout):
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	case errors.Is(err, session.ErrNack):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"imei":     req.IMEI,
+		"command":  req.Command,
+		"response": resp,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"codec-svr/internal/store"
+	"codec-svr/internal/store/storetest"
+)
+
+func TestRequireToken(t *testing.T) {
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	for _, tc := range []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"no token configured", "", "", http.StatusNoContent},
+		{"missing header", "s3cret", "", http.StatusUnauthorized},
+		{"wrong token", "s3cret", "Bearer nope", http.StatusUnauthorized},
+		{"no bearer prefix", "s3cret", "s3cret", http.StatusUnauthorized},
+		{"valid", "s3cret", "Bearer s3cret", http.StatusNoContent},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/sessions", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			requireToken(tc.token, ok)(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleQuarantine(t *testing.T) {
+	storetest.Start(t)
+	for i := 0; i < 150; i++ {
+		q := store.QuarantinedFrame{IMEI: "352093081452251", Kind: "truncated", Offset: i, Record: -1}
+		if err := store.QuarantineFrame(t.Context(), q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		query  string
+		want   int
+		frames int
+	}{
+		{"default limit", http.MethodGet, "", http.StatusOK, 100},
+		{"limit", http.MethodGet, "?limit=5", http.StatusOK, 5},
+		{"limit above stored", http.MethodGet, "?limit=500", http.StatusOK, 150},
+		{"zero limit", http.MethodGet, "?limit=0", http.StatusBadRequest, 0},
+		{"bad limit", http.MethodGet, "?limit=abc", http.StatusBadRequest, 0},
+		{"post", http.MethodPost, "", http.StatusMethodNotAllowed, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleQuarantine(rec, httptest.NewRequest(tc.method, "/admin/quarantine"+tc.query, nil))
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.want)
+			}
+			if tc.want != http.StatusOK {
+				return
+			}
+			var got []store.QuarantinedFrame
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tc.frames {
+				t.Fatalf("%d frames, want %d", len(got), tc.frames)
+			}
+			// más recientes primero
+			if got[0].Offset != 149 || got[len(got)-1].Offset != 150-tc.frames {
+				t.Fatalf("order: first %d, last %d", got[0].Offset, got[len(got)-1].Offset)
+			}
+		})
+	}
+}
+
+func TestHandleQuarantineRedisDown(t *testing.T) {
+	storetest.Start(t)
+	store.Close()
+
+	rec := httptest.NewRecorder()
+	handleQuarantine(rec, httptest.NewRequest(http.MethodGet, "/admin/quarantine?limit=1", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
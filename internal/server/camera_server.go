@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"codec-svr/internal/codec"
+	"codec-svr/internal/dispatcher"
+	"codec-svr/internal/observability"
+	"codec-svr/internal/pipeline"
+)
+
+// Archivos que se piden al equipo según los bits del Init, en este orden.
+var camSources = []struct {
+	bit  uint8
+	path string
+}{
+	{codec.CamHasFrontPhoto, "%photof"},
+	{codec.CamHasRearPhoto, "%photor"},
+	{codec.CamHasFrontVideo, "%videof"},
+	{codec.CamHasRearVideo, "%videor"},
+}
+
+// Si el equipo deja de mandar paquetes este tiempo se corta la sesión; lo
+// recibido queda en disco para reanudar. Un .part sin tocar por más de
+// camPartialMaxAge ya no se reanuda.
+const (
+	camReadTimeout   = 2 * time.Minute
+	camWriteTimeout  = 30 * time.Second
+	camPartialMaxAge = 24 * time.Hour
+)
+
+// camPartial es el estado de un archivo a medio recibir, guardado junto al
+// .part para poder reanudar tras una reconexión.
+type camPartial struct {
+	EventDT  time.Time `json:"event_dt"`
+	EventIO  int       `json:"event_io"`
+	Total    uint32    `json:"total"`
+	Received uint32    `json:"received"` // paquetes escritos en el .part
+	Bytes    int64     `json:"bytes"`    // largo del .part que corresponde a Received
+	Updated  time.Time `json:"updated"`
+}
+
+// -------------------------------------------------------------------
+
+// StartCamera atiende el protocolo de archivos de DualCam / ADAS y deja los
+// archivos completos en mediaDir/<imei>/. Con ctx cancelado deja de
+// aceptar, corta las transferencias en curso (se reanudan en la próxima
+// conexión) y vuelve cuando ningún handler sigue corriendo, de modo que
+// ya no habrá más dispatcher.Submit.
+func StartCamera(ctx context.Context, addr, mediaDir string) error {
+	lg := observability.NewLogger()
+	if err := os.MkdirAll(mediaDir, 0o755); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	lg.Info("camera listening", "addr", addr, "media_dir", mediaDir)
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var (
+		mu       sync.Mutex
+		open     = make(map[net.Conn]struct{})
+		handlers sync.WaitGroup
+	)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			lg.Error("camera accept", "err", err)
+			continue
+		}
+		mu.Lock()
+		open[conn] = struct{}{}
+		mu.Unlock()
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			handleCamConn(ctx, conn, mediaDir, lg.With("remote", conn.RemoteAddr().String()))
+			mu.Lock()
+			delete(open, conn)
+			mu.Unlock()
+		}()
+	}
+
+	// Cortar la lectura: cada handler guarda su estado y termina
+	mu.Lock()
+	for c := range open {
+		c.SetReadDeadline(time.Now())
+	}
+	mu.Unlock()
+	handlers.Wait()
+	lg.Info("camera listener stopped")
+	return nil
+}
+
+// -------------------------------------------------------------------
+
+func handleCamConn(ctx context.Context, conn net.Conn, mediaDir string, lg *slog.Logger) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+
+	// Cada paquete renueva el plazo de lectura y pisaría el corte del
+	// apagado: después de renovarlo se mira ctx
+	next := func() (codec.CamPacket, error) {
+		conn.SetReadDeadline(time.Now().Add(camReadTimeout))
+		if err := ctx.Err(); err != nil {
+			return codec.CamPacket{}, err
+		}
+		return codec.ReadCamPacket(br)
+	}
+	send := func(b []byte) error {
+		conn.SetWriteDeadline(time.Now().Add(camWriteTimeout))
+		_, err := conn.Write(b)
+		return err
+	}
+
+	hello, err := next()
+	if err != nil {
+		lg.Warn("camera: init not read", "err", err)
+		return
+	}
+	if hello.Cmd != codec.CamInit {
+		lg.Warn("camera: expected init", "cmd", fmt.Sprintf("0x%04X", hello.Cmd))
+		return
+	}
+	imei := hello.IMEI
+	lg = lg.With("imei", imei)
+	lg.Info("camera session", "files", fmt.Sprintf("0x%02X", hello.Files))
+
+	dir := filepath.Join(mediaDir, imei)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		lg.Error("camera: media dir", "err", err)
+		return
+	}
+
+	for _, src := range camSources {
+		if hello.Files&src.bit == 0 {
+			continue
+		}
+		if err := send(codec.BuildCamRequest(src.path)); err != nil {
+			lg.Warn("camera: request not sent", "source", src.path, "err", err)
+			return
+		}
+		mo, err := receiveCamFile(ctx, send, next, dir, imei, src.path)
+		if err != nil {
+			if err != io.EOF {
+				lg.Warn("camera: transfer interrupted", "source", src.path, "err", err)
+			}
+			return
+		}
+		mo.Datetime = time.Now().UTC().Format(time.RFC3339)
+		dispatcher.Submit(imei, func(context.Context) { dispatcher.ProcessMedia(mo) })
+	}
+
+	if err := send(codec.BuildCamComplete()); err != nil {
+		lg.Warn("camera: complete not sent", "err", err)
+	}
+}
+
+// receiveCamFile recibe un archivo pedido: Start → Resume → (Sync, Data...)
+// hasta completar los paquetes anunciados. Un Data con CRC inválido o un
+// Sync fuera de secuencia se contestan con Resume desde el último paquete
+// bueno.
+func receiveCamFile(ctx context.Context, send func([]byte) error, next func() (codec.CamPacket, error), dir, imei, source string) (*pipeline.MediaObject, error) {
+	p, err := next()
+	if err != nil {
+		return nil, err
+	}
+	if p.Cmd != codec.CamStart {
+		return nil, fmt.Errorf("expected start, got 0x%04X", p.Cmd)
+	}
+
+	name := strings.TrimPrefix(source, "%")
+	partPath := filepath.Join(dir, name+".part")
+	statePath := partPath + ".json"
+
+	st, f, err := openCamPartial(ctx, imei, partPath, statePath, p.Total)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	if err := send(codec.BuildCamResume(st.Received)); err != nil {
+		return nil, err
+	}
+	synced := false
+
+	for st.Received < st.Total {
+		p, err := next()
+		if err != nil {
+			saveCamState(statePath, st)
+			return nil, err
+		}
+		switch p.Cmd {
+		case codec.CamSync:
+			synced = p.Offset == st.Received
+			if !synced {
+				if err := send(codec.BuildCamResume(st.Received)); err != nil {
+					saveCamState(statePath, st)
+					return nil, err
+				}
+			}
+
+		case codec.CamData:
+			if !synced {
+				continue // se espera el Sync del Resume
+			}
+			if !p.CRCOK {
+				synced = false
+				if err := send(codec.BuildCamResume(st.Received)); err != nil {
+					saveCamState(statePath, st)
+					return nil, err
+				}
+				continue
+			}
+			if _, err := f.Write(p.Data); err != nil {
+				return nil, err
+			}
+			st.Received++
+			st.Bytes += int64(len(p.Data))
+			if st.Received%64 == 0 {
+				saveCamState(statePath, st)
+			}
+
+		default:
+			return nil, fmt.Errorf("unexpected command 0x%04X during transfer", p.Cmd)
+		}
+	}
+
+	size := st.Bytes
+	f.Close()
+	f = nil
+
+	kind, ext := "photo", ".jpg"
+	if strings.HasPrefix(name, "video") {
+		kind, ext = "video", ".h265"
+	}
+	final := filepath.Join(dir, fmt.Sprintf("%d_%s%s", st.EventDT.Unix(), name, ext))
+	if err := os.Rename(partPath, final); err != nil {
+		return nil, err
+	}
+	os.Remove(statePath)
+
+	return &pipeline.MediaObject{
+		IMEI:    imei,
+		EventDT: st.EventDT.Format(time.RFC3339),
+		EventIO: st.EventIO,
+		Source:  source,
+		Kind:    kind,
+		Path:    final,
+		Size:    size,
+	}, nil
+}
+
+// openCamPartial reanuda el .part si corresponde al mismo archivo: mismo
+// evento AVL (el último del equipo), mismo total de paquetes y no más
+// viejo que camPartialMaxAge. Si no, empieza de cero enlazado al último
+// evento.
+func openCamPartial(ctx context.Context, imei, partPath, statePath string, total uint32) (camPartial, *os.File, error) {
+	fresh := camPartial{Total: total, EventDT: time.Now().UTC()}
+	ts, id, known := dispatcher.LastEvent(ctx, imei)
+	if known {
+		fresh.EventDT, fresh.EventIO = ts, id
+	}
+
+	var st camPartial
+	if b, err := os.ReadFile(statePath); err == nil && json.Unmarshal(b, &st) == nil &&
+		st.Total == total && st.Received <= total &&
+		time.Since(st.Updated) < camPartialMaxAge &&
+		(!known || st.EventDT.Equal(fresh.EventDT)) {
+		// lo escrito después del último estado guardado se descarta
+		f, err := os.OpenFile(partPath, os.O_WRONLY, 0o644)
+		if err == nil {
+			if err = f.Truncate(st.Bytes); err == nil {
+				if _, err = f.Seek(st.Bytes, io.SeekStart); err == nil {
+					return st, f, nil
+				}
+			}
+			f.Close()
+		}
+	}
+
+	st = fresh
+	f, err := os.Create(partPath)
+	if err != nil {
+		return st, nil, err
+	}
+	saveCamState(statePath, st)
+	return st, f, nil
+}
+
+func saveCamState(path string, st camPartial) {
+	st.Updated = time.Now().UTC()
+	b, _ := json.Marshal(st)
+	_ = os.WriteFile(path, b, 0o644)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHandleCamConnStopsOnShutdown(t *testing.T) {
+	const imei = "352093081452281"
+	mediaDir := t.TempDir()
+	srv, dev := net.Pipe()
+	defer dev.Close()
+	go io.Copy(io.Discard, dev)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		handleCamConn(ctx, srv, mediaDir, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		close(done)
+	}()
+
+	init, _ := hex.DecodeString("0000" + "0" + imei + "01") // Init: sólo foto frontal
+	start := binary.BigEndian.AppendUint32([]byte{0x00, 0x01}, 1000)
+	sync := binary.BigEndian.AppendUint32([]byte{0x00, 0x03}, 0)
+	data := append([]byte{0x00, 0x04, 0x00, 0x09}, "123456789"...)
+	data = append(data, 0x31, 0xC3) // CRC-16/XMODEM de "123456789"
+
+	for _, p := range [][]byte{init, start, sync} {
+		if _, err := dev.Write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// El equipo no deja de mandar: cada paquete renueva el plazo de lectura
+	go func() {
+		for i := 0; ; i++ {
+			if i == 10 {
+				// lo que hace StartCamera al apagar
+				cancel()
+				srv.SetReadDeadline(time.Now())
+			}
+			if _, err := dev.Write(data); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("camera handler kept reading after shutdown")
+	}
+
+	b, err := os.ReadFile(filepath.Join(mediaDir, imei, "photof.part.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st camPartial
+	if err := json.Unmarshal(b, &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Total != 1000 || st.Received < 10 || st.Bytes != int64(st.Received)*9 {
+		t.Fatalf("saved state = %+v", st)
+	}
+}
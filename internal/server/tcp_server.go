@@ -1,27 +1,30 @@
 package server
 
 import (
-	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
-	"strings"
+	"sync"
 	"time"
 
 	"codec-svr/internal/codec"
 	"codec-svr/internal/dispatcher"
 	"codec-svr/internal/observability"
+	"codec-svr/internal/profile"
+	"codec-svr/internal/session"
 	"codec-svr/internal/store"
 )
 
 type connState struct {
 	imei  string
-	buf   bytes.Buffer
 	ready bool
 	log   *slog.Logger
+	sess  *session.Session // registrada tras el handshake; todas las escrituras pasan por aquí
 
 	sentGetVer        bool
 	sentICCID         bool
@@ -34,214 +37,390 @@ type connState struct {
 	lastGetVerAttempt time.Time
 }
 
+// Options ajusta el comportamiento del listener TCP.
+type Options struct {
+	MaxFrameSize int // bytes; 0 = codec.DefaultMaxFrameSize
+
+	// Plazos de la conexión; 0 = valor por defecto.
+	HandshakeTimeout time.Duration // desde el accept hasta el IMEI
+	IdleTimeout      time.Duration // sin ningún frame (el keepalive 0xFF cuenta)
+	WriteTimeout     time.Duration // por escritura (ACKs, comandos)
+}
+
+var (
+	maxFrameSize     = codec.DefaultMaxFrameSize
+	handshakeTimeout = 30 * time.Second
+	idleTimeout      = 10 * time.Minute
+)
+
+// Motivos de cierre de conexión (label de codec_tcp_closes_total).
+const (
+	closeEOF              = "eof"
+	closeHandshakeTimeout = "handshake_timeout"
+	closeIdleTimeout      = "idle_timeout"
+	closeReadError        = "read_error"
+	closeWriteError       = "write_error"
+	closeRejected         = "rejected"
+	closeTakeover         = "takeover"
+	closeShutdown         = "shutdown"
+)
+
+// Conexiones aceptadas y aún abiertas: en el apagado se les corta la
+// lectura y se cierran recién después de drenar el dispatcher.
+var (
+	connsMu sync.Mutex
+	conns   = make(map[net.Conn]struct{})
+	readers sync.WaitGroup
+)
+
 // -------------------------------------------------------------------
 
-func Start(addr string) error {
+// Start atiende el listener TCP hasta que se cancele ctx. Entonces deja de
+// aceptar, corta la lectura de las conexiones abiertas (lo que llegue ya no
+// se confirma) y vuelve cuando ningún handleConn está leyendo. Las
+// conexiones quedan abiertas hasta CloseConnections.
+func Start(ctx context.Context, addr string, opt Options) error {
+	if opt.MaxFrameSize > 0 {
+		maxFrameSize = opt.MaxFrameSize
+	}
+	if opt.HandshakeTimeout > 0 {
+		handshakeTimeout = opt.HandshakeTimeout
+	}
+	if opt.IdleTimeout > 0 {
+		idleTimeout = opt.IdleTimeout
+	}
+	if opt.WriteTimeout > 0 {
+		session.WriteTimeout = opt.WriteTimeout
+	}
+
 	lg := observability.NewLogger()
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
 	lg.Info("tcp listening", "addr", addr)
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
 
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
 			lg.Error("accept", "err", err)
 			continue
 		}
 		observability.TCPConnections.Inc()
 
-		go handleConn(conn, lg.With("remote", conn.RemoteAddr().String()))
+		connsMu.Lock()
+		conns[conn] = struct{}{}
+		connsMu.Unlock()
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			handleConn(ctx, conn, lg.With("remote", conn.RemoteAddr().String()))
+		}()
+	}
+
+	// Destrabar los Read pendientes
+	connsMu.Lock()
+	for c := range conns {
+		c.SetReadDeadline(time.Now())
+	}
+	connsMu.Unlock()
+	readers.Wait()
+	lg.Info("tcp listener stopped")
+	return nil
+}
+
+// CloseConnections cierra las sesiones y conexiones que quedaron abiertas
+// tras el apagado de Start.
+func CloseConnections() {
+	session.CloseAll()
+	connsMu.Lock()
+	defer connsMu.Unlock()
+	for c := range conns {
+		c.Close()
+		delete(conns, c)
+		observability.TCPCloses.WithLabelValues(closeShutdown).Inc()
 	}
 }
 
 // -------------------------------------------------------------------
 
-func handleConn(conn net.Conn, lg *slog.Logger) {
-	defer conn.Close()
+func handleConn(ctx context.Context, conn net.Conn, lg *slog.Logger) {
 	var st connState
 	st.log = lg
-
-	tmp := make([]byte, 4096)
+	reason := closeEOF
+	defer func() {
+		if reason == closeShutdown {
+			// se cierra en CloseConnections, después de drenar
+			return
+		}
+		connsMu.Lock()
+		delete(conns, conn)
+		connsMu.Unlock()
+		conn.Close()
+		session.Unregister(st.sess)
+		observability.TCPCloses.WithLabelValues(reason).Inc()
+		lg.Info("connection closed", "imei", st.imei, "reason", reason)
+	}()
+
+	fr := codec.NewFrameReader(conn, maxFrameSize)
+	fr.OnResync = func(reason string, skipped int) {
+		observability.FrameResyncs.WithLabelValues(reason).Inc()
+		observability.FrameResyncBytes.Add(float64(skipped))
+		lg.Warn("frame resync", "imei", st.imei, "reason", reason, "skipped", skipped)
+	}
 	firstAVLACK := false
 
 	for {
-		n, err := conn.Read(tmp)
+		// Cada frame (keepalive incluido) renueva el plazo de lectura
+		if st.imei == "" {
+			conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+		} else {
+			conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
+		if ctx.Err() != nil {
+			reason = closeShutdown
+			return
+		}
+
+		f, err := fr.Next()
 		if err != nil {
-			if err != io.EOF {
+			if ctx.Err() != nil {
+				reason = closeShutdown
+				return
+			}
+			reason = readCloseReason(err, &st)
+			if reason == closeReadError {
 				lg.Error("read", "err", err)
 			}
 			return
 		}
-		if n == 0 {
-			continue
+
+		if st.sess != nil {
+			st.sess.Touch()
 		}
-		st.buf.Write(tmp[:n])
+
+		switch f.Kind {
+		case codec.FrameKeepalive:
+			continue
 
 		// ---- Handshake IMEI ----
-		if st.imei == "" {
-			if tryReadIMEI(&st) {
-				lg.Info("handshake OK", "imei", st.imei)
-				conn.Write([]byte{0x01})
-				st.ready = true
-				st.sessionOpen = time.Now()
+		case codec.FrameIMEI:
+			if st.imei != "" {
+				lg.Warn("repeated imei handshake", "imei", st.imei, "got", f.IMEI)
+				continue
 			}
+			sess, prev, err := session.Register(f.IMEI, conn)
+			if prev != nil {
+				dispatcher.ProcessTakeover(f.IMEI, string(session.DupPolicy), prev.Remote, conn.RemoteAddr().String())
+			}
+			if err != nil {
+				// reject_new: handshake rechazado
+				lg.Warn("handshake rejected", "imei", f.IMEI, "err", err)
+				if session.WriteTimeout > 0 {
+					conn.SetWriteDeadline(time.Now().Add(session.WriteTimeout))
+				}
+				conn.Write([]byte{0x00})
+				reason = closeRejected
+				return
+			}
+			st.imei = f.IMEI
+			st.sess = sess
+			lg.Info("handshake OK", "imei", st.imei)
+			observability.HandshakeOK.Inc()
+			if err := st.sess.Write([]byte{0x01}); err != nil {
+				reason = closeWriteError
+				return
+			}
+			st.ready = true
+			st.sessionOpen = time.Now()
 			continue
 		}
 
 		// ---- Procesar frames ----
-		for {
-			pkt := tryReadAVLFrame(&st.buf)
-			if pkt == nil {
-				break
+		if st.imei == "" {
+			lg.Warn("frame before handshake", "frame", f.String())
+			continue
+		}
+
+		pkt := f.Data
+		observability.PacketsRecv.Inc()
+		if len(pkt) < 13 {
+			lg.Warn("short frame", "len", len(pkt))
+			continue
+		}
+
+		codecID := f.CodecID
+
+		// =====================================================
+		//      RESPUESTA CODEC 12 (comandos)
+		// =====================================================
+		if codecID == codec.CodecID12 {
+
+			// DEBUG — ver frame RAW de las respuestas de comando
+			lg.Warn("CODEC12 RAW RESPONSE", "hex", hex.EncodeToString(pkt))
+
+			if text, err := codec.ParseCodec12Response(pkt); err == nil {
+				// respuesta a un comando ad-hoc (session.SendCommand) o a uno automático
+				if !st.sess.Deliver(text) {
+					dispatcher.HandleCommandResponses(ctx, st.imei, text)
+				}
+			} else {
+				lg.Warn("codec12: frame not parsed", "err", err)
 			}
+			continue
+		}
 
-			observability.PacketsRecv.Inc()
-			if len(pkt) < 13 {
-				lg.Warn("short frame", "len", len(pkt))
+		// =====================================================
+		//      RESPUESTA CODEC 14 (comandos por IMEI)
+		// =====================================================
+		if codecID == codec.CodecID14 {
+			res, err := codec.ParseCodec14Response(pkt)
+			if err != nil {
+				lg.Warn("codec14: frame not parsed", "err", err)
 				continue
 			}
+			if !res.Ack {
+				st.sess.DeliverNack()
+				dispatcher.HandleCommandNack(st.imei, res.IMEI)
+				continue
+			}
+			if !st.sess.Deliver(res.Text) {
+				dispatcher.HandleCommandResponses(ctx, st.imei, res.Text)
+			}
+			continue
+		}
 
-			codecID := pkt[8]
-
-			// =====================================================
-			//      RESPUESTA CODEC 12 (comandos)
-			// =====================================================
-			if codecID == 0x0C {
+		// =====================================================
+		//      MENSAJE CODEC 13 (unidireccional, sin respuesta)
+		// =====================================================
+		if codecID == codec.CodecID13 {
+			msg, err := codec.ParseCodec13(pkt)
+			if err != nil {
+				lg.Warn("codec13: frame not parsed", "err", err)
+				continue
+			}
+			dispatcher.Submit(st.imei, func(context.Context) { dispatcher.ProcessCodec13(st.imei, msg) })
+			continue
+		}
 
-				// DEBUG — ver frame RAW de las respuestas de comando
-				lg.Warn("CODEC12 RAW RESPONSE", "hex", hex.EncodeToString(pkt))
+		// =====================================================
+		//      DATOS SERIE CODEC 15 (FMX6 RS232)
+		// =====================================================
+		if codecID == codec.CodecID15 {
+			msg, err := codec.ParseCodec15(pkt)
+			if err != nil {
+				lg.Warn("codec15: frame not parsed", "err", err)
+				continue
+			}
+			dispatcher.Submit(st.imei, func(context.Context) { dispatcher.ProcessSerial(st.imei, msg) })
+			continue
+		}
 
-				if text, err := codec.ParseCodec12Response(pkt); err == nil {
-					dispatcher.HandleGetVerResponse(st.imei, text)
-					dispatcher.HandleICCIDResponse(st.imei, text)
-				} else {
-					lg.Warn("codec12: frame not parsed", "err", err)
-				}
+		// =====================================================
+		//          AVL FRAME
+		// =====================================================
+		if codec.IsAVLCodec(codecID) {
+			// Sin ACK si el CRC no cuadra: el equipo retransmite el frame
+			if err := codec.VerifyFrameCRC(pkt); err != nil {
+				observability.CRCErrors.Inc()
+				lg.Warn("avl frame rejected", "imei", st.imei, "err", err)
+				dispatcher.Submit(st.imei, func(ctx context.Context) { dispatcher.QuarantineFrame(ctx, st.imei, pkt, err) })
 				continue
 			}
 
+			qty1 := int(pkt[9])
+
+			dispatcher.Submit(st.imei, func(ctx context.Context) { dispatcher.ProcessIncoming(ctx, st.imei, pkt) })
+
+			var ack [4]byte
+			binary.BigEndian.PutUint32(ack[:], uint32(qty1))
+			if err := st.sess.Write(ack[:]); err != nil {
+				lg.Warn("ack write failed", "imei", st.imei, "err", err)
+				reason = closeWriteError
+				return
+			}
+			observability.RecordsAck.Inc()
+			firstAVLACK = true
+
 			// =====================================================
-			//          AVL FRAME
+			//      GETVER con reintentos
 			// =====================================================
-			if codecID == 0x08 || codecID == 0x8E {
-				qty1 := int(pkt[9])
-
-				go dispatcher.ProcessIncoming(st.imei, pkt)
-
-				var ack [4]byte
-				binary.BigEndian.PutUint32(ack[:], uint32(qty1))
-				conn.Write(ack[:])
-				observability.RecordsAck.Inc()
-				firstAVLACK = true
-
-				// =====================================================
-				//      GETVER con reintentos
-				// =====================================================
-				if st.ready && firstAVLACK {
-					maybeSendGetVer(&st, conn)
-				}
+			if st.ready && firstAVLACK {
+				maybeSendGetVer(ctx, &st)
+			}
 
-				// =====================================================
-				//   *** FLUJO ICCID ORIGINAL ***
-				// =====================================================
-				if st.sentGetVer && !st.sentICCID && !st.sentICCIDFallback {
-					model := dispatcher.GetCachedModel(st.imei)
+			// =====================================================
+			//   FLUJO ICCID según el perfil del modelo
+			// =====================================================
+			if st.sentGetVer && !st.sentICCID && !st.sentICCIDFallback {
+				prof := profile.ForIMEI(ctx, st.imei)
 
-					// Caso 1: modelo desconocido → intentar getimeiccid
-					if model == "" {
-						cmd := codec.BuildCodec12("getimeiccid")
-						conn.Write(cmd)
-						st.sentICCID = true
-						lg.Info("sent ICCID (unknown model)", "imei", st.imei)
-						continue
+				switch prof.ICCID {
+				// familia 650 -> fallback directo
+				case profile.ICCIDGetParam:
+					if prof.Allows("getparam") {
+						cmd := codec.BuildCodec12("getparam 219,220,221")
+						if err := st.sess.SendAuto(cmd); err != nil {
+							break // comando ad-hoc en vuelo: se reintenta en el próximo AVL
+						}
+						lg.Info("sent ICCID fallback", "imei", st.imei, "profile", prof.Name)
 					}
+					st.sentICCIDFallback = true
 
-					ml := strings.ToLower(model)
+				// el ICCID llega en los IO 219/220/221 o no se pide
+				case profile.ICCIDFromAVL, profile.ICCIDNone:
+					st.sentICCID = true
 
-					// Caso 2: familia 650 -> fallback directo
-					if strings.Contains(ml, "650") {
-						cmd := codec.BuildCodec12("getparam 219,220,221")
-						conn.Write(cmd)
-						st.sentICCIDFallback = true
-						lg.Info("sent ICCID fallback (650)", "imei", st.imei)
-						continue
+				// modelo desconocido u otros modelos -> getimeiccid
+				default:
+					if prof.Allows("getimeiccid") {
+						cmd := codec.BuildCodec12("getimeiccid")
+						if err := st.sess.SendAuto(cmd); err != nil {
+							break
+						}
+						lg.Info("sent ICCID via getimeiccid", "imei", st.imei, "profile", prof.Name)
 					}
-
-					// Caso 3: otros modelos -> getimeiccid normal
-					cmd := codec.BuildCodec12("getimeiccid")
-					conn.Write(cmd)
 					st.sentICCID = true
-					lg.Info("sent ICCID via getimeiccid", "imei", st.imei)
 				}
-
-				continue
 			}
 
-			lg.Warn("unknown codec", "id", fmt.Sprintf("0x%02X", codecID))
+			continue
 		}
-	}
-}
 
-// -------------------------------------------------------------------
-
-func tryReadIMEI(st *connState) bool {
-	if st.buf.Len() < 2 {
-		return false
-	}
-	peek := st.buf.Bytes()
-	imeiLen := int(binary.BigEndian.Uint16(peek[:2]))
-	if imeiLen < 8 || imeiLen > 20 {
-		return false
-	}
-	if st.buf.Len() < 2+imeiLen {
-		return false
+		lg.Warn("unknown codec", "id", fmt.Sprintf("0x%02X", codecID))
 	}
-
-	st.buf.Next(2)
-	imeiBytes := st.buf.Next(imeiLen)
-
-	for _, b := range imeiBytes {
-		if b < '0' || b > '9' {
-			return false
-		}
-	}
-
-	st.imei = string(imeiBytes)
-	return true
 }
 
-func tryReadAVLFrame(buf *bytes.Buffer) []byte {
-	if buf.Len() < 12 {
-		return nil
+// readCloseReason clasifica el error de lectura que terminó la conexión.
+func readCloseReason(err error, st *connState) string {
+	if st.sess != nil && st.sess.Closed() {
+		return closeTakeover // cerrada por DUP_SESSION_POLICY=close_old
 	}
-	peek := buf.Bytes()
-	if !(peek[0] == 0 && peek[1] == 0 && peek[2] == 0 && peek[3] == 0) {
-		discard := bytes.Index(peek, []byte{0, 0, 0, 0})
-		if discard < 0 {
-			buf.Reset()
-			return nil
-		}
-		buf.Next(discard)
-		if buf.Len() < 12 {
-			return nil
+	var ne net.Error
+	switch {
+	case errors.Is(err, io.EOF):
+		return closeEOF
+	case errors.As(err, &ne) && ne.Timeout():
+		if st.imei == "" {
+			return closeHandshakeTimeout
 		}
-		peek = buf.Bytes()
+		return closeIdleTimeout
 	}
-	dataLen := int(binary.BigEndian.Uint32(peek[4:8]))
-	frameLen := 4 + 4 + dataLen + 4
-	if buf.Len() < frameLen {
-		return nil
-	}
-	return buf.Next(frameLen)
+	return closeReadError
 }
 
 // -------------------------------------------------------------------
 //              ** NUEVO: LÓGICA DE REINTENTOS GETVER **
 // -------------------------------------------------------------------
 
-func maybeSendGetVer(st *connState, conn net.Conn) {
+func maybeSendGetVer(ctx context.Context, st *connState) {
 	const (
 		maxSessionAttempts = 3
 		minInterval        = 5 * time.Minute
@@ -259,15 +438,20 @@ func maybeSendGetVer(st *connState, conn net.Conn) {
 	// Pero ahora lo usaremos como "primer intento enviado"
 	// y reintentos vendrán por esta función.
 
-	fw := store.GetStringSafe("dev:" + st.imei + ":fw")
-	model := store.GetStringSafe("dev:" + st.imei + ":model")
+	fw := store.GetStringSafe(ctx, "dev:"+st.imei+":fw")
+	model := store.GetStringSafe(ctx, "dev:"+st.imei+":model")
 
 	// 2. Si ya tenemos valores → no reintentar
 	if fw != "" && model != "" {
 		return
 	}
 
-	// 3. Límite por sesión
+	// 3. El perfil del modelo debe permitir el comando
+	if !profile.Resolve(model, fw).Allows(cmdName) {
+		return
+	}
+
+	// 4. Límite por sesión
 	if st.getVerAttempts >= maxSessionAttempts {
 		st.log.Info("getver session limit reached",
 			"imei", st.imei,
@@ -275,14 +459,19 @@ func maybeSendGetVer(st *connState, conn net.Conn) {
 		return
 	}
 
-	// 4. Mínimo tiempo entre intentos
+	// 5. Mínimo tiempo entre intentos
 	if !st.lastGetVerAttempt.IsZero() &&
 		now.Sub(st.lastGetVerAttempt) < minInterval {
 		return
 	}
 
-	// 5. Límite diario global por IMEI
-	allowed, dailyCount, err := store.IncDailyCmdCounter(st.imei, cmdName, maxDailyAttempts)
+	// 6. No mezclar con un comando ad-hoc en vuelo
+	if st.sess.Busy() {
+		return
+	}
+
+	// 7. Límite diario global por IMEI
+	allowed, dailyCount, err := store.IncDailyCmdCounter(ctx, st.imei, cmdName, maxDailyAttempts)
 	if err != nil {
 		st.log.Warn("redis counter failed for getver", "err", err)
 		return
@@ -296,7 +485,10 @@ func maybeSendGetVer(st *connState, conn net.Conn) {
 
 	// ---- Enviar GETVER ----
 	cmd := codec.BuildCodec12("getver")
-	conn.Write(cmd)
+	if err := st.sess.SendAuto(cmd); err != nil {
+		st.log.Warn("getver not sent", "imei", st.imei, "err", err)
+		return
+	}
 
 	st.sentGetVer = true
 	st.getVerAttempts++
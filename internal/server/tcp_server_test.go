@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"codec-svr/internal/observability"
+	"codec-svr/internal/session"
+)
+
+// testConn corre handleConn sobre un net.Pipe; done se cierra al volver.
+func testConn(t *testing.T) (dev net.Conn, done chan struct{}) {
+	t.Helper()
+	srv, dev := net.Pipe()
+	done = make(chan struct{})
+	go func() {
+		handleConn(context.Background(), srv, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		close(done)
+	}()
+	t.Cleanup(func() {
+		dev.Close()
+		<-done
+	})
+	return dev, done
+}
+
+// handshake manda el IMEI y devuelve el byte de respuesta del servidor.
+func handshake(t *testing.T, dev net.Conn, imei string) byte {
+	t.Helper()
+	dev.SetDeadline(time.Now().Add(time.Second))
+	defer dev.SetDeadline(time.Time{})
+	if _, err := dev.Write(append([]byte{0x00, byte(len(imei))}, imei...)); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(dev, b); err != nil {
+		t.Fatal(err)
+	}
+	return b[0]
+}
+
+func waitDone(t *testing.T, done chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("connection not closed: %s", what)
+	}
+}
+
+// closes devuelve el contador de cierres por motivo.
+func closes(reason string) float64 {
+	return testutil.ToFloat64(observability.TCPCloses.WithLabelValues(reason))
+}
+
+func usePolicy(t *testing.T, p session.Policy) {
+	old := session.DupPolicy
+	session.DupPolicy = p
+	t.Cleanup(func() { session.DupPolicy = old })
+}
+
+func TestDuplicateSessionCloseOld(t *testing.T) {
+	const imei = "352093081452291"
+	usePolicy(t, session.PolicyCloseOld)
+	before := closes(closeTakeover)
+
+	devA, doneA := testConn(t)
+	if b := handshake(t, devA, imei); b != 0x01 {
+		t.Fatalf("first handshake = 0x%02X", b)
+	}
+	devB, doneB := testConn(t)
+	if b := handshake(t, devB, imei); b != 0x01 {
+		t.Fatalf("second handshake = 0x%02X", b)
+	}
+
+	waitDone(t, doneA, "old session after takeover")
+	if got := closes(closeTakeover) - before; got != 1 {
+		t.Fatalf("takeover closes += %v, want 1", got)
+	}
+	s, ok := session.Get(imei)
+	if !ok || s.Closed() {
+		t.Fatal("new session not registered")
+	}
+	select {
+	case <-doneB:
+		t.Fatal("new session closed")
+	default:
+	}
+}
+
+func TestDuplicateSessionRejectNew(t *testing.T) {
+	const imei = "352093081452292"
+	usePolicy(t, session.PolicyRejectNew)
+	before := closes(closeRejected)
+
+	devA, doneA := testConn(t)
+	if b := handshake(t, devA, imei); b != 0x01 {
+		t.Fatalf("first handshake = 0x%02X", b)
+	}
+	old, _ := session.Get(imei)
+
+	devB, doneB := testConn(t)
+	if b := handshake(t, devB, imei); b != 0x00 {
+		t.Fatalf("duplicate handshake = 0x%02X, want 0x00", b)
+	}
+	waitDone(t, doneB, "rejected handshake")
+	if got := closes(closeRejected) - before; got != 1 {
+		t.Fatalf("rejected closes += %v, want 1", got)
+	}
+	if s, ok := session.Get(imei); !ok || s != old || s.Closed() {
+		t.Fatal("first session replaced or closed")
+	}
+	select {
+	case <-doneA:
+		t.Fatal("first session closed")
+	default:
+	}
+}
+
+func TestDuplicateSessionRejectWriteDeadline(t *testing.T) {
+	const imei = "352093081452293"
+	usePolicy(t, session.PolicyRejectNew)
+	old := session.WriteTimeout
+	session.WriteTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { session.WriteTimeout = old })
+
+	devA, _ := testConn(t)
+	handshake(t, devA, imei)
+
+	// El equipo nuevo manda el IMEI y no lee más: el 0x00 no puede
+	// trabar la goroutine de la conexión
+	devB, doneB := testConn(t)
+	devB.SetWriteDeadline(time.Now().Add(time.Second))
+	if _, err := devB.Write(append([]byte{0x00, byte(len(imei))}, imei...)); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, doneB, "reject write without a deadline")
+}
+
+func TestDuplicateSessionAllow(t *testing.T) {
+	const imei = "352093081452294"
+	usePolicy(t, session.PolicyAllow)
+
+	devA, doneA := testConn(t)
+	handshake(t, devA, imei)
+	first, _ := session.Get(imei)
+	devB, doneB := testConn(t)
+	if b := handshake(t, devB, imei); b != 0x01 {
+		t.Fatalf("second handshake = 0x%02X", b)
+	}
+
+	s, ok := session.Get(imei)
+	if !ok || s == first || first.Closed() {
+		t.Fatal("registry should point to the new session and keep the old one open")
+	}
+	// Al irse la vieja no da de baja a la nueva
+	devA.Close()
+	waitDone(t, doneA, "old session after EOF")
+	if s2, ok := session.Get(imei); !ok || s2 != s {
+		t.Fatal("new session unregistered by the old one")
+	}
+	select {
+	case <-doneB:
+		t.Fatal("new session closed")
+	default:
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	const imei = "352093081452295"
+	old := idleTimeout
+	idleTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { idleTimeout = old })
+	before := closes(closeIdleTimeout)
+
+	dev, done := testConn(t)
+	handshake(t, dev, imei)
+
+	// Los keepalive 0xFF renuevan el plazo
+	for i := 0; i < 6; i++ {
+		time.Sleep(40 * time.Millisecond)
+		if _, err := dev.Write([]byte{0xFF}); err != nil {
+			t.Fatalf("keepalive %d: %v", i, err)
+		}
+	}
+	select {
+	case <-done:
+		t.Fatal("closed while receiving keepalives")
+	default:
+	}
+
+	waitDone(t, done, "idle connection")
+	if got := closes(closeIdleTimeout) - before; got != 1 {
+		t.Fatalf("idle closes += %v, want 1", got)
+	}
+	if _, ok := session.Get(imei); ok {
+		t.Fatal("idle session still registered")
+	}
+}
+
+func TestReadCloseReason(t *testing.T) {
+	srv, dev := net.Pipe()
+	defer dev.Close()
+	taken, _, err := session.Register("352093081452296", srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taken.Close()
+	session.Unregister(taken)
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		st   connState
+		want string
+	}{
+		{"eof", io.EOF, connState{imei: "352093081452251"}, closeEOF},
+		{"handshake timeout", os.ErrDeadlineExceeded, connState{}, closeHandshakeTimeout},
+		{"idle timeout", os.ErrDeadlineExceeded, connState{imei: "352093081452251"}, closeIdleTimeout},
+		{"read error", errors.New("connection reset by peer"), connState{imei: "352093081452251"}, closeReadError},
+		{"session taken over", io.EOF, connState{imei: "352093081452296", sess: taken}, closeTakeover},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := readCloseReason(tc.err, &tc.st); got != tc.want {
+				t.Fatalf("reason = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
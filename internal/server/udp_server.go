@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"codec-svr/internal/codec"
+	"codec-svr/internal/dispatcher"
+	"codec-svr/internal/observability"
+)
+
+// Ventana en la que un AVL packet ID repetido se considera retransmisión.
+// Pasado este tiempo el ID (1 byte, rota) se acepta como paquete nuevo.
+const udpDedupWindow = 5 * time.Minute
+
+// Espera entre lecturas fallidas del socket UDP: se duplica hasta udpMaxBackoff.
+const (
+	udpMinBackoff = 5 * time.Millisecond
+	udpMaxBackoff = time.Second
+)
+
+type udpSeen struct {
+	avlPacketID uint8
+	at          time.Time
+}
+
+var (
+	udpMu   sync.Mutex
+	udpLast = make(map[string]udpSeen)
+)
+
+// -------------------------------------------------------------------
+
+// StartUDP atiende el listener UDP hasta que se cancele ctx. Cada datagrama
+// se encola en el mismo loop, así que al volver no habrá más
+// dispatcher.Submit desde UDP.
+func StartUDP(ctx context.Context, addr string) error {
+	lg := observability.NewLogger()
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	lg.Info("udp listening", "addr", addr)
+	go func() {
+		<-ctx.Done()
+		pc.Close()
+	}()
+
+	buf := make([]byte, 65535)
+	backoff := time.Duration(0)
+	for {
+		n, raddr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				lg.Info("udp listener stopped")
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			// Error persistente del socket: no girar en vacío
+			backoff = nextUDPBackoff(backoff)
+			lg.Error("udp read", "err", err, "retry_in", backoff)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+		dgram := make([]byte, n)
+		copy(dgram, buf[:n])
+
+		handleDatagram(pc, raddr, dgram, lg.With("remote", raddr.String()))
+	}
+}
+
+// -------------------------------------------------------------------
+
+func handleDatagram(pc net.PacketConn, raddr net.Addr, dgram []byte, lg *slog.Logger) {
+	observability.UDPPackets.Inc()
+
+	up, err := codec.ParseUDPPacket(dgram)
+	if err != nil {
+		lg.Warn("udp: packet not parsed", "err", err)
+		return
+	}
+
+	// Retransmisión: el equipo no recibió nuestro ACK. Se confirma otra vez
+	// pero NO se vuelve a procesar.
+	if isUDPDuplicate(up.IMEI, up.AVLPacketID) {
+		observability.UDPDuplicates.Inc()
+		if len(up.Data) >= 2 {
+			pc.WriteTo(codec.BuildUDPAck(up.PacketID, up.AVLPacketID, up.Data[1]), raddr)
+		}
+		lg.Info("udp duplicate", "imei", up.IMEI, "avl_packet_id", up.AVLPacketID)
+		return
+	}
+
+	// Sin ACK si no decodifica: el equipo retransmite
+	// El view aliasa dgram (propio de este datagrama) y lo libera el worker
+	v := codec.AcquirePacketView()
+	if err := codec.DecodeAVLView(up.Data, v); err != nil {
+		codec.ReleasePacketView(v)
+		observability.ParseErrors.Inc()
+		lg.Warn("udp: avl data not parsed", "imei", up.IMEI, "err", err)
+		dispatcher.TrySubmit(up.IMEI, func(ctx context.Context) { dispatcher.QuarantineFrame(ctx, up.IMEI, up.Data, err) })
+		return
+	}
+	observability.PacketsRecv.Inc()
+	qty1 := v.Qty1
+
+	// Cola del shard llena: sin ACK, el equipo retransmite más tarde
+	if !dispatcher.TrySubmit(up.IMEI, func(ctx context.Context) {
+		defer codec.ReleasePacketView(v)
+		dispatcher.ProcessPacket(ctx, up.IMEI, v)
+	}) {
+		codec.ReleasePacketView(v)
+		lg.Warn("udp: worker queue full, not acked", "imei", up.IMEI, "avl_packet_id", up.AVLPacketID)
+		return
+	}
+
+	pc.WriteTo(codec.BuildUDPAck(up.PacketID, up.AVLPacketID, qty1), raddr)
+	observability.RecordsAck.Inc()
+	markUDPSeen(up.IMEI, up.AVLPacketID)
+}
+
+func nextUDPBackoff(d time.Duration) time.Duration {
+	if d == 0 {
+		return udpMinBackoff
+	}
+	if d *= 2; d > udpMaxBackoff {
+		return udpMaxBackoff
+	}
+	return d
+}
+
+func isUDPDuplicate(imei string, avlPacketID uint8) bool {
+	udpMu.Lock()
+	defer udpMu.Unlock()
+	last, ok := udpLast[imei]
+	return ok && last.avlPacketID == avlPacketID && time.Since(last.at) < udpDedupWindow
+}
+
+func markUDPSeen(imei string, avlPacketID uint8) {
+	udpMu.Lock()
+	defer udpMu.Unlock()
+	udpLast[imei] = udpSeen{avlPacketID: avlPacketID, at: time.Now()}
+}
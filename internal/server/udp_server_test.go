@@ -0,0 +1,59 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"codec-svr/internal/codec"
+)
+
+func TestHandleDatagramRetransmission(t *testing.T) {
+	const imei = "352093081452251"
+	srv, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer srv.Close()
+	dev, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer dev.Close()
+
+	// AVL data Codec 8 con un record (qty1 = 1)
+	data, _ := hex.DecodeString("08010000016B40D8EA30010000000000000000000000000000000105021503010101425E0F01F10000601A014E000000000000000001")
+	body := binary.BigEndian.AppendUint16(nil, 0xCAFF)
+	body = append(body, 0x01, 0x05)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(imei)))
+	body = append(append(body, imei...), data...)
+	dgram := append(binary.BigEndian.AppendUint16(nil, uint16(len(body))), body...)
+
+	// Ya confirmado: se vuelve a confirmar con el packetID nuevo, sin procesar
+	markUDPSeen(imei, 0x05)
+	t.Cleanup(func() {
+		udpMu.Lock()
+		delete(udpLast, imei)
+		udpMu.Unlock()
+	})
+	handleDatagram(srv, dev.LocalAddr(), dgram, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	dev.SetReadDeadline(time.Now().Add(time.Second))
+	ack := make([]byte, 16)
+	n, _, err := dev.ReadFrom(ack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := codec.BuildUDPAck(0xCAFF, 0x05, 1); !bytes.Equal(ack[:n], want) {
+		t.Fatalf("ack = % X, want % X", ack[:n], want)
+	}
+
+	if isUDPDuplicate(imei, 0x06) {
+		t.Fatal("new avl packet id taken as a retransmission")
+	}
+}
@@ -0,0 +1,357 @@
+// Package session mantiene el registro IMEI → conexión TCP viva, para
+// poder escribirle a un equipo desde fuera de su handleConn.
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"codec-svr/internal/codec"
+)
+
+var (
+	ErrOffline   = errors.New("device not connected")
+	ErrTimeout   = errors.New("command response timeout")
+	ErrClosed    = errors.New("session closed")
+	ErrDuplicate = errors.New("imei already has a live session")
+	ErrNack      = errors.New("device rejected command: imei mismatch")
+	ErrBusy      = errors.New("ad-hoc command in flight")
+)
+
+// Policy decide qué hacer cuando un IMEI hace handshake teniendo ya una
+// sesión viva (típico tras un timeout de NAT: la conexión vieja sigue
+// abierta del lado del servidor).
+type Policy string
+
+const (
+	PolicyCloseOld  Policy = "close_old"  // se cierra la sesión vieja y queda la nueva
+	PolicyRejectNew Policy = "reject_new" // se rechaza el handshake nuevo
+	PolicyAllow     Policy = "allow"      // conviven; el registro apunta a la nueva
+)
+
+// DupPolicy es la política vigente; main la fija desde DUP_SESSION_POLICY.
+var DupPolicy = PolicyCloseOld
+
+// ParsePolicy valida el nombre de una política.
+func ParsePolicy(v string) (Policy, error) {
+	switch p := Policy(v); p {
+	case PolicyCloseOld, PolicyRejectNew, PolicyAllow:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown duplicate session policy %q", v)
+}
+
+// CommandTimeout es la espera por defecto de SendCommand y el plazo tras
+// el cual un comando automático sin respuesta deja de esperarla.
+var CommandTimeout = 30 * time.Second
+
+// MaxCommandTimeout acota la espera de un comando ad-hoc (la sesión no
+// acepta otro mientras tanto).
+const MaxCommandTimeout = 2 * time.Minute
+
+// WriteTimeout acota cada escritura a la conexión (0 = sin plazo).
+var WriteTimeout = 10 * time.Second
+
+// Session es la conexión viva de un equipo ya identificado por IMEI.
+type Session struct {
+	IMEI   string
+	Remote string
+	Opened time.Time
+
+	lastSeen atomic.Int64 // unix nano del último frame recibido
+
+	conn net.Conn
+	wmu  sync.Mutex // serializa escrituras (ACKs, comandos automáticos y ad-hoc)
+
+	cmdMu   sync.Mutex    // un comando ad-hoc en vuelo por sesión
+	pmu     sync.Mutex    // protege pending y ordena sus escrituras
+	pending []outstanding // comandos enviados sin respuesta, en orden de envío
+	done    chan struct{}
+	once    sync.Once
+}
+
+var (
+	mu       sync.RWMutex
+	sessions = make(map[string]*Session)
+)
+
+// Register da de alta la sesión del IMEI. Si ya había una viva se aplica
+// DupPolicy y se devuelve la previa en prev para que el llamador registre
+// el takeover; con PolicyRejectNew s es nil y err es ErrDuplicate.
+func Register(imei string, conn net.Conn) (s, prev *Session, err error) {
+	s = &Session{
+		IMEI:   imei,
+		Remote: conn.RemoteAddr().String(),
+		Opened: time.Now(),
+		conn:   conn,
+		done:   make(chan struct{}),
+	}
+
+	s.Touch()
+
+	mu.Lock()
+	prev = sessions[imei]
+	if prev != nil && DupPolicy == PolicyRejectNew {
+		mu.Unlock()
+		return nil, prev, ErrDuplicate
+	}
+	sessions[imei] = s
+	mu.Unlock()
+
+	if prev != nil && DupPolicy == PolicyCloseOld {
+		prev.Close()
+	}
+	return s, prev, nil
+}
+
+// Close cierra la conexión de la sesión; su handleConn termina al fallar
+// la lectura y se da de baja solo.
+func (s *Session) Close() {
+	s.once.Do(func() { close(s.done) })
+	s.conn.Close()
+}
+
+// Closed indica si la sesión ya fue cerrada (por Close o Unregister).
+func (s *Session) Closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Unregister da de baja s si sigue siendo la sesión vigente de su IMEI y
+// destraba un SendCommand en espera.
+func Unregister(s *Session) {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	if sessions[s.IMEI] == s {
+		delete(sessions, s.IMEI)
+	}
+	mu.Unlock()
+	s.once.Do(func() { close(s.done) })
+}
+
+// CloseAll cierra y da de baja todas las sesiones (apagado).
+func CloseAll() {
+	mu.Lock()
+	all := make([]*Session, 0, len(sessions))
+	for imei, s := range sessions {
+		all = append(all, s)
+		delete(sessions, imei)
+	}
+	mu.Unlock()
+	for _, s := range all {
+		s.Close()
+	}
+}
+
+// Get devuelve la sesión vigente del IMEI.
+func Get(imei string) (*Session, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := sessions[imei]
+	return s, ok
+}
+
+// Touch marca actividad del equipo (cualquier frame, keepalive incluido).
+func (s *Session) Touch() {
+	s.lastSeen.Store(time.Now().UnixNano())
+}
+
+// LastSeen devuelve el momento del último frame recibido.
+func (s *Session) LastSeen() time.Time {
+	return time.Unix(0, s.lastSeen.Load())
+}
+
+// Info es la vista pública de una sesión para listados.
+type Info struct {
+	IMEI     string    `json:"imei"`
+	Remote   string    `json:"remote"`
+	Opened   time.Time `json:"opened"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// List devuelve las sesiones vigentes ordenadas por IMEI.
+func List() []Info {
+	mu.RLock()
+	out := make([]Info, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, Info{IMEI: s.IMEI, Remote: s.Remote, Opened: s.Opened, LastSeen: s.LastSeen()})
+	}
+	mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].IMEI < out[j].IMEI })
+	return out
+}
+
+// Write escribe b en la conexión; seguro entre goroutines.
+func (s *Session) Write(b []byte) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if WriteTimeout > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	}
+	_, err := s.conn.Write(b)
+	return err
+}
+
+// reply es la respuesta de un comando ad-hoc; nack sólo en Codec 14.
+type reply struct {
+	text string
+	nack bool
+}
+
+// outstanding es un comando enviado que espera respuesta. El equipo
+// contesta los comandos en orden, así que cada respuesta corresponde al
+// más viejo sin vencer. ch es nil en los automáticos (getver, ICCID...).
+type outstanding struct {
+	ch       chan reply
+	deadline time.Time
+}
+
+// Deliver entrega la respuesta de un comando (Codec 12 o 14). Devuelve
+// true si era la de un SendCommand en espera; con false es la respuesta
+// de un comando automático (o no pedida) y va a sus manejadores.
+func (s *Session) Deliver(text string) bool {
+	return s.deliver(reply{text: text})
+}
+
+// DeliverNack entrega un nACK de Codec 14 (ver Deliver).
+func (s *Session) DeliverNack() bool {
+	return s.deliver(reply{nack: true})
+}
+
+func (s *Session) deliver(r reply) bool {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	now := time.Now()
+	for len(s.pending) > 0 {
+		o := s.pending[0]
+		s.pending = s.pending[1:]
+		if now.After(o.deadline) {
+			continue // nunca contestó: la respuesta es de uno posterior
+		}
+		if o.ch == nil {
+			return false
+		}
+		o.ch <- r // buffer de 1, un solo envío por comando
+		return true
+	}
+	return false
+}
+
+// Busy indica si hay un comando ad-hoc esperando respuesta.
+func (s *Session) Busy() bool {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	now := time.Now()
+	for _, o := range s.pending {
+		if o.ch != nil && now.Before(o.deadline) {
+			return true
+		}
+	}
+	return false
+}
+
+// prune descarta los comandos vencidos (deliver los saltearía igual); así
+// pending no crece con un equipo que nunca contesta. Con pmu tomado.
+func (s *Session) prune(now time.Time) {
+	n := 0
+	for _, o := range s.pending {
+		if !now.After(o.deadline) {
+			s.pending[n] = o
+			n++
+		}
+	}
+	clear(s.pending[n:])
+	s.pending = s.pending[:n]
+}
+
+// SendAuto manda un comando automático del servidor. Con un comando
+// ad-hoc en vuelo no se envía (ErrBusy): el automático se reintenta solo
+// y así su respuesta nunca se confunde con la del operador.
+func (s *Session) SendAuto(frame []byte) error {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	now := time.Now()
+	s.prune(now)
+	for _, o := range s.pending {
+		if o.ch != nil && now.Before(o.deadline) {
+			return ErrBusy
+		}
+	}
+	if err := s.Write(frame); err != nil {
+		return err
+	}
+	s.pending = append(s.pending, outstanding{deadline: now.Add(CommandTimeout)})
+	return nil
+}
+
+// SendCommand manda text como Codec 12 al equipo y espera su respuesta
+// hasta CommandTimeout.
+func SendCommand(imei, text string) (string, error) {
+	return SendCommandTimeout(imei, text, CommandTimeout)
+}
+
+// SendCommandTimeout es SendCommand con espera explícita (hasta
+// MaxCommandTimeout). Los comandos ad-hoc a un mismo equipo se envían de a
+// uno; las respuestas a comandos automáticos enviados antes no se toman
+// como la del comando en vuelo.
+func SendCommandTimeout(imei, text string, timeout time.Duration) (string, error) {
+	return send(imei, codec.BuildCodec12(text), timeout)
+}
+
+// SendCommand14 manda text como Codec 14, dirigido al IMEI de la sesión:
+// si el equipo no es ese IMEI contesta nACK y se devuelve ErrNack.
+func SendCommand14(imei, text string, timeout time.Duration) (string, error) {
+	frame, err := codec.BuildCodec14(imei, text)
+	if err != nil {
+		return "", err
+	}
+	return send(imei, frame, timeout)
+}
+
+func send(imei string, frame []byte, timeout time.Duration) (string, error) {
+	s, ok := Get(imei)
+	if !ok {
+		return "", ErrOffline
+	}
+	if timeout > MaxCommandTimeout {
+		timeout = MaxCommandTimeout
+	}
+	s.cmdMu.Lock()
+	defer s.cmdMu.Unlock()
+
+	// Encolar y escribir juntos: el orden de pending es el del cable
+	ch := make(chan reply, 1)
+	s.pmu.Lock()
+	s.prune(time.Now())
+	if err := s.Write(frame); err != nil {
+		s.pmu.Unlock()
+		return "", err
+	}
+	s.pending = append(s.pending, outstanding{ch: ch, deadline: time.Now().Add(timeout)})
+	s.pmu.Unlock()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case r := <-ch:
+		if r.nack {
+			return "", ErrNack
+		}
+		return r.text, nil
+	case <-s.done:
+		return "", ErrClosed
+	case <-t.C:
+		return "", ErrTimeout
+	}
+}
@@ -0,0 +1,205 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"codec-svr/internal/codec"
+)
+
+// testSession registra una sesión sobre net.Pipe y descarta lo que se le
+// escribe al "equipo".
+func testSession(t *testing.T, imei string) *Session {
+	t.Helper()
+	srv, dev := net.Pipe()
+	go io.Copy(io.Discard, dev)
+	s, _, err := Register(imei, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		Unregister(s)
+		s.Close()
+		dev.Close()
+	})
+	return s
+}
+
+// waitBusy espera a que SendCommand haya encolado su comando.
+func waitBusy(t *testing.T, s *Session) {
+	t.Helper()
+	for i := 0; !s.Busy(); i++ {
+		if i > 200 {
+			t.Fatal("ad-hoc command never sent")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeliverCorrelatesBySendOrder(t *testing.T) {
+	const imei = "352093081452251"
+	s := testSession(t, imei)
+
+	// getver automático enviado antes que el comando del operador
+	if err := s.SendAuto(codec.BuildCodec12("getver")); err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		text string
+		err  error
+	}
+	res := make(chan result, 1)
+	go func() {
+		text, err := SendCommandTimeout(imei, "getgps", time.Second)
+		res <- result{text, err}
+	}()
+	waitBusy(t, s)
+
+	if err := s.SendAuto(codec.BuildCodec12("getimeiccid")); !errors.Is(err, ErrBusy) {
+		t.Fatalf("automatic send during ad-hoc: %v", err)
+	}
+	if s.Deliver("Ver:03.27.07 Hw:FMB920") {
+		t.Fatal("getver reply was taken by the ad-hoc command")
+	}
+	if !s.Deliver("GPS:1 Sat:9") {
+		t.Fatal("ad-hoc reply not delivered")
+	}
+	if r := <-res; r.err != nil || r.text != "GPS:1 Sat:9" {
+		t.Fatalf("SendCommand = %q, %v", r.text, r.err)
+	}
+	if s.Busy() {
+		t.Fatal("still busy after the reply")
+	}
+	if s.Deliver("unsolicited") {
+		t.Fatal("reply with nothing pending was delivered")
+	}
+}
+
+func TestDeliverSkipsExpiredAuto(t *testing.T) {
+	const imei = "352093081452252"
+	s := testSession(t, imei)
+
+	old := CommandTimeout
+	CommandTimeout = time.Millisecond
+	t.Cleanup(func() { CommandTimeout = old })
+
+	// automático que nunca contesta: vence y no se come la respuesta ad-hoc
+	if err := s.SendAuto(codec.BuildCodec12("getver")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	res := make(chan error, 1)
+	go func() {
+		_, err := SendCommandTimeout(imei, "getstatus", time.Second)
+		res <- err
+	}()
+	waitBusy(t, s)
+	if !s.DeliverNack() {
+		t.Fatal("nACK not delivered to the ad-hoc command")
+	}
+	if err := <-res; !errors.Is(err, ErrNack) {
+		t.Fatalf("err = %v, want ErrNack", err)
+	}
+}
+
+func TestSendCommandTimeout(t *testing.T) {
+	const imei = "352093081452253"
+	s := testSession(t, imei)
+
+	if _, err := SendCommandTimeout(imei, "getver", 10*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	// la respuesta tardía ya no es del comando vencido
+	if s.Deliver("late") {
+		t.Fatal("late reply delivered")
+	}
+	if _, err := SendCommandTimeout("000000000000000", "getver", time.Second); !errors.Is(err, ErrOffline) {
+		t.Fatalf("err = %v, want ErrOffline", err)
+	}
+}
+
+// pendingLen devuelve cuántos comandos esperan respuesta.
+func pendingLen(s *Session) int {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	return len(s.pending)
+}
+
+func TestSendPrunesExpired(t *testing.T) {
+	const imei = "352093081452254"
+	s := testSession(t, imei)
+
+	old := CommandTimeout
+	CommandTimeout = time.Millisecond
+	t.Cleanup(func() { CommandTimeout = old })
+
+	// Un equipo que nunca contesta: los automáticos vencidos no se acumulan
+	for i := 0; i < 5; i++ {
+		if err := s.SendAuto(codec.BuildCodec12("getver")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(3 * time.Millisecond)
+	}
+	if n := pendingLen(s); n != 1 {
+		t.Fatalf("%d pending after SendAuto, want 1", n)
+	}
+
+	// El ad-hoc que vence tampoco queda en la cola del siguiente
+	if _, err := SendCommandTimeout(imei, "getgps", 5*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	res := make(chan error, 1)
+	go func() {
+		_, err := SendCommandTimeout(imei, "getstatus", time.Second)
+		res <- err
+	}()
+	waitBusy(t, s)
+	if n := pendingLen(s); n != 1 {
+		t.Fatalf("%d pending after SendCommand, want 1", n)
+	}
+	if !s.Deliver("Data Link: 1") {
+		t.Fatal("reply not delivered to the live command")
+	}
+	if err := <-res; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendAutoBusy(t *testing.T) {
+	const imei = "352093081452255"
+	s := testSession(t, imei)
+
+	res := make(chan error, 1)
+	go func() {
+		_, err := SendCommandTimeout(imei, "getgps", 30*time.Millisecond)
+		res <- err
+	}()
+	waitBusy(t, s)
+
+	for i := 0; i < 3; i++ {
+		if err := s.SendAuto(codec.BuildCodec12("getver")); !errors.Is(err, ErrBusy) {
+			t.Fatalf("SendAuto during ad-hoc = %v, want ErrBusy", err)
+		}
+	}
+	if n := pendingLen(s); n != 1 {
+		t.Fatalf("%d pending, want only the ad-hoc command", n)
+	}
+
+	// Vencido el ad-hoc, el automático vuelve a salir
+	if err := <-res; !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if s.Busy() {
+		t.Fatal("busy after the ad-hoc command expired")
+	}
+	if err := s.SendAuto(codec.BuildCodec12("getver")); err != nil {
+		t.Fatalf("SendAuto after expiry: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	crashTraceTTL  = 30 * 24 * time.Hour
+	crashIndexKeep = 50 // IDs recientes por equipo en dev:<imei>:crashes
+)
+
+// SaveCrashTrace guarda el trazo serializado en crash:<id> y lo indexa en
+// dev:<imei>:crashes (más reciente primero).
+func SaveCrashTrace(ctx context.Context, imei, id string, data []byte) error {
+	if rdb == nil {
+		return fmt.Errorf("redis not initialized")
+	}
+	idx := "dev:" + imei + ":crashes"
+	pipe := rdb.TxPipeline()
+	pipe.Set(ctx, "crash:"+id, data, crashTraceTTL)
+	pipe.LPush(ctx, idx, id)
+	pipe.LTrim(ctx, idx, 0, crashIndexKeep-1)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetCrashTrace devuelve el JSON del trazo id ("" si no existe o expiró).
+func GetCrashTrace(ctx context.Context, id string) string {
+	return GetStringSafe(ctx, "crash:"+id)
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Frames que no se pudieron decodificar, para revisarlos después.
+const (
+	quarantineKey = "quarantine:frames"
+	QuarantineMax = 1000 // se conservan sólo los más recientes
+)
+
+// QuarantinedFrame es un frame malformado con el contexto del error.
+type QuarantinedFrame struct {
+	IMEI   string    `json:"imei"`
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Error  string    `json:"error"`
+	Codec  string    `json:"codec,omitempty"`
+	Offset int       `json:"offset"`
+	Record int       `json:"record"`
+	Model  string    `json:"model,omitempty"`
+	FWVer  string    `json:"fw_ver,omitempty"`
+	Hex    string    `json:"hex"`
+}
+
+// QuarantineFrame guarda q al principio de la lista y la recorta a QuarantineMax.
+func QuarantineFrame(ctx context.Context, q QuarantinedFrame) error {
+	if rdb == nil {
+		return fmt.Errorf("redis not initialized")
+	}
+	b, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	pipe := rdb.TxPipeline()
+	pipe.LPush(ctx, quarantineKey, b)
+	pipe.LTrim(ctx, quarantineKey, 0, QuarantineMax-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ListQuarantine devuelve hasta limit frames, del más reciente al más antiguo.
+func ListQuarantine(ctx context.Context, limit int) ([]QuarantinedFrame, error) {
+	if rdb == nil {
+		return nil, fmt.Errorf("redis not initialized")
+	}
+	if limit <= 0 || limit > QuarantineMax {
+		limit = QuarantineMax
+	}
+	vals, err := rdb.LRange(ctx, quarantineKey, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]QuarantinedFrame, 0, len(vals))
+	for _, v := range vals {
+		var q QuarantinedFrame
+		if err := json.Unmarshal([]byte(v), &q); err != nil {
+			continue
+		}
+		out = append(out, q)
+	}
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package store_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"codec-svr/internal/store"
+	"codec-svr/internal/store/storetest"
+)
+
+func TestQuarantineKeepsMostRecent(t *testing.T) {
+	storetest.Start(t)
+
+	// QuarantineMax+5 frames: los 5 más viejos se descartan
+	base := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	total := store.QuarantineMax + 5
+	for i := 0; i < total; i++ {
+		q := store.QuarantinedFrame{
+			IMEI:   "352093081452251",
+			Time:   base.Add(time.Duration(i) * time.Second),
+			Kind:   "truncated",
+			Error:  "frame " + strconv.Itoa(i),
+			Offset: i,
+			Record: -1,
+			Hex:    "00",
+		}
+		if err := store.QuarantineFrame(t.Context(), q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := store.ListQuarantine(t.Context(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != store.QuarantineMax {
+		t.Fatalf("%d frames kept, want %d", len(all), store.QuarantineMax)
+	}
+	for i, q := range all {
+		if want := total - 1 - i; q.Offset != want {
+			t.Fatalf("frame %d has offset %d, want %d (newest first)", i, q.Offset, want)
+		}
+	}
+	if !all[0].Time.Equal(base.Add(time.Duration(total-1)*time.Second)) || all[0].Kind != "truncated" {
+		t.Fatalf("newest = %+v", all[0])
+	}
+
+	for _, tc := range []struct{ limit, want int }{
+		{1, 1},
+		{10, 10},
+		{-1, store.QuarantineMax},
+		{store.QuarantineMax * 2, store.QuarantineMax},
+	} {
+		got, err := store.ListQuarantine(t.Context(), tc.limit)
+		if err != nil || len(got) != tc.want {
+			t.Errorf("ListQuarantine(%d) = %d frames, %v; want %d", tc.limit, len(got), err, tc.want)
+		}
+	}
+}
+
+func TestQuarantineSkipsUnreadableEntries(t *testing.T) {
+	r := storetest.Start(t)
+
+	if err := store.QuarantineFrame(t.Context(), store.QuarantinedFrame{IMEI: "352093081452251", Kind: "bad_crc"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.List("quarantine:frames"); len(got) != 1 {
+		t.Fatalf("list = %v", got)
+	}
+	r.Push("quarantine:frames", "not json")
+
+	got, err := store.ListQuarantine(t.Context(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Kind != "bad_crc" {
+		t.Fatalf("frames = %+v", got)
+	}
+}
@@ -9,15 +9,15 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-var ctx = context.Background()
 var rdb *redis.Client
 
+// InitRedis conecta con Redis.
 func InitRedis(addr string, db int) error {
 	rdb = redis.NewClient(&redis.Options{
 		Addr: addr,
 		DB:   db,
 	})
-	_, err := rdb.Ping(ctx).Result()
+	_, err := rdb.Ping(context.Background()).Result()
 	if err != nil {
 		return fmt.Errorf("redis ping failed: %w", err)
 	}
@@ -25,7 +25,15 @@ func InitRedis(addr string, db int) error {
 	return nil
 }
 
-func SaveEventRedisSafe(key string, value int, ttl ...time.Duration) {
+// Close cierra la conexión con Redis.
+func Close() error {
+	if rdb == nil {
+		return nil
+	}
+	return rdb.Close()
+}
+
+func SaveEventRedisSafe(ctx context.Context, key string, value int, ttl ...time.Duration) {
 	if rdb == nil {
 		fmt.Println("[WARN] redis not initialized")
 		return
@@ -41,7 +49,7 @@ func SaveEventRedisSafe(key string, value int, ttl ...time.Duration) {
 	}
 }
 
-func GetStateRedis(key string) (int, bool) {
+func GetStateRedis(ctx context.Context, key string) (int, bool) {
 	if rdb == nil {
 		return 0, false
 	}
@@ -52,7 +60,7 @@ func GetStateRedis(key string) (int, bool) {
 	n, _ := strconv.Atoi(val)
 	return n, true
 }
-func GetStatesRedis(keys []string) map[string]int {
+func GetStatesRedis(ctx context.Context, keys []string) map[string]int {
 	out := make(map[string]int, len(keys))
 	if rdb == nil || len(keys) == 0 {
 		return out
@@ -73,7 +81,7 @@ func GetStatesRedis(keys []string) map[string]int {
 }
 
 // al final del archivo
-func SaveStringSafe(key, value string, ttl ...time.Duration) {
+func SaveStringSafe(ctx context.Context, key, value string, ttl ...time.Duration) {
 	if rdb == nil {
 		fmt.Println("[WARN] redis not initialized")
 		return
@@ -87,7 +95,7 @@ func SaveStringSafe(key, value string, ttl ...time.Duration) {
 	}
 }
 
-func GetStringSafe(key string) string {
+func GetStringSafe(ctx context.Context, key string) string {
 	if rdb == nil {
 		return ""
 	}
@@ -98,14 +106,14 @@ func GetStringSafe(key string) string {
 	return s
 }
 
-func HSetPermIO(imei string, id uint16, val uint64) {
+func HSetPermIO(ctx context.Context, imei string, id uint16, val uint64) {
 	key := imei
 	field := strconv.Itoa(int(id))
 	_ = rdb.HSet(ctx, key, field, strconv.FormatUint(val, 10)).Err()
 }
 
 // Obtiene el hash completo como map[string]uint64
-func HGetAllPermIO(imei string) map[string]uint64 {
+func HGetAllPermIO(ctx context.Context, imei string) map[string]uint64 {
 	key := imei
 	out := map[string]uint64{}
 	m, err := rdb.HGetAll(ctx, key).Result()
@@ -128,7 +136,7 @@ func HGetAllPermIO(imei string) map[string]uint64 {
 //	allowed = true  si todavía está por debajo o igual que max
 //	allowed = false si ya excedió max (pero el contador igualmente subió)
 //	current = valor actual del contador tras el INCR
-func IncDailyCmdCounter(imei, cmd string, max int) (allowed bool, current int, err error) {
+func IncDailyCmdCounter(ctx context.Context, imei, cmd string, max int) (allowed bool, current int, err error) {
 	if rdb == nil {
 		return false, 0, fmt.Errorf("redis not initialized")
 	}
@@ -0,0 +1,257 @@
+// Package storetest levanta un Redis en memoria para los tests: habla
+// RESP2 y sólo entiende los comandos que usa el paquete store.
+package storetest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"codec-svr/internal/store"
+)
+
+// Redis es el estado del servidor falso.
+type Redis struct {
+	mu      sync.Mutex
+	strings map[string]string
+	lists   map[string][]string
+	hashes  map[string]map[string]string
+}
+
+// Start levanta el servidor falso, conecta store a él y lo cierra al
+// terminar el test.
+func Start(t testing.TB) *Redis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no loopback listener:", err)
+	}
+	r := &Redis{
+		strings: make(map[string]string),
+		lists:   make(map[string][]string),
+		hashes:  make(map[string]map[string]string),
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(c)
+		}
+	}()
+	if err := store.InitRedis(ln.Addr().String(), 0); err != nil {
+		ln.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+		ln.Close()
+	})
+	return r
+}
+
+// List devuelve una copia de la lista key.
+func (r *Redis) List(key string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lists[key]...)
+}
+
+// Push agrega val al principio de la lista key (como LPUSH).
+func (r *Redis) Push(key, val string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lists[key] = append([]string{val}, r.lists[key]...)
+}
+
+// Get devuelve el string key.
+func (r *Redis) Get(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.strings[key]
+	return v, ok
+}
+
+// Set guarda el string key.
+func (r *Redis) Set(key, val string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strings[key] = val
+}
+
+func (r *Redis) serve(c net.Conn) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	bw := bufio.NewWriter(c)
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(br)
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		switch {
+		case cmd == "MULTI":
+			inMulti, queued = true, nil
+			bw.WriteString("+OK\r\n")
+		case cmd == "EXEC":
+			fmt.Fprintf(bw, "*%d\r\n", len(queued))
+			for _, q := range queued {
+				bw.WriteString(r.exec(q))
+			}
+			inMulti, queued = false, nil
+		case cmd == "DISCARD":
+			inMulti, queued = false, nil
+			bw.WriteString("+OK\r\n")
+		case inMulti:
+			queued = append(queued, args)
+			bw.WriteString("+QUEUED\r\n")
+		default:
+			bw.WriteString(r.exec(args))
+		}
+		if br.Buffered() == 0 {
+			if bw.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+func (r *Redis) exec(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cmd, a := strings.ToUpper(args[0]), args[1:]
+	switch {
+	case cmd == "PING":
+		return "+PONG\r\n"
+	case cmd == "SELECT":
+		return "+OK\r\n"
+	case cmd == "GET" && len(a) == 1:
+		if v, ok := r.strings[a[0]]; ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case cmd == "SET" && len(a) >= 2:
+		r.strings[a[0]] = a[1]
+		return "+OK\r\n"
+	case cmd == "MGET":
+		out := fmt.Sprintf("*%d\r\n", len(a))
+		for _, k := range a {
+			if v, ok := r.strings[k]; ok {
+				out += bulk(v)
+			} else {
+				out += "$-1\r\n"
+			}
+		}
+		return out
+	case cmd == "INCR" && len(a) == 1:
+		n, _ := strconv.Atoi(r.strings[a[0]])
+		n++
+		r.strings[a[0]] = strconv.Itoa(n)
+		return fmt.Sprintf(":%d\r\n", n)
+	case cmd == "EXPIRE":
+		return ":1\r\n"
+	case cmd == "HSET" && len(a) >= 3:
+		h := r.hashes[a[0]]
+		if h == nil {
+			h = make(map[string]string)
+			r.hashes[a[0]] = h
+		}
+		added := 0
+		for i := 1; i+1 < len(a); i += 2 {
+			if _, ok := h[a[i]]; !ok {
+				added++
+			}
+			h[a[i]] = a[i+1]
+		}
+		return fmt.Sprintf(":%d\r\n", added)
+	case cmd == "HGETALL" && len(a) == 1:
+		h := r.hashes[a[0]]
+		out := fmt.Sprintf("*%d\r\n", 2*len(h))
+		for k, v := range h {
+			out += bulk(k) + bulk(v)
+		}
+		return out
+	case cmd == "LPUSH" && len(a) >= 2:
+		l := r.lists[a[0]]
+		for _, v := range a[1:] {
+			l = append([]string{v}, l...)
+		}
+		r.lists[a[0]] = l
+		return fmt.Sprintf(":%d\r\n", len(l))
+	case (cmd == "LTRIM" || cmd == "LRANGE") && len(a) == 3:
+		l := r.lists[a[0]]
+		start, _ := strconv.Atoi(a[1])
+		stop, _ := strconv.Atoi(a[2])
+		lo, hi := listRange(len(l), start, stop)
+		if cmd == "LTRIM" {
+			r.lists[a[0]] = append([]string(nil), l[lo:hi]...)
+			return "+OK\r\n"
+		}
+		out := fmt.Sprintf("*%d\r\n", hi-lo)
+		for _, v := range l[lo:hi] {
+			out += bulk(v)
+		}
+		return out
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+// listRange traduce start/stop de Redis (inclusive, negativos desde el
+// final) a un rango [lo, hi) de Go.
+func listRange(n, start, stop int) (int, int) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+// readCommand lee un comando RESP (array de bulk strings).
+func readCommand(br *bufio.Reader) ([]string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[0] != '*' {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad array %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("bad bulk %q", line)
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}